@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	myImg "day01/minitools/myimage"
+	"day01/minitools/myimage/annotation"
+)
+
+// runAnnotate minitools img annotate -format yolo -labels dir [-classes file] [-out dir] imageDir
+func runAnnotate(args []string) error {
+	fs := flag.NewFlagSet("img annotate", flag.ExitOnError)
+	format := fs.String("format", "yolo", "annotation format: yolo, coco or voc")
+	labels := fs.String("labels", "", "yolo label dir, coco json file or voc xml dir")
+	classesPath := fs.String("classes", "", "yolo class names file, one per line")
+	out := fs.String("out", "annotated", "output dir")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *labels == "" {
+		return errors.New("usage: minitools img annotate -format yolo|coco|voc -labels path [-classes file] [-out dir] imageDir")
+	}
+	imageDir := fs.Arg(0)
+	f, err := annotation.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	var classes []string
+	if *classesPath != "" {
+		if classes, err = annotation.ReadClasses(*classesPath); err != nil {
+			return err
+		}
+	}
+	d, err := annotation.Load(f, *labels, imageDir, classes)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+
+	for _, img := range d.Images {
+		pic, err := loadPicture(filepath.Join(imageDir, img.File))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "minitools:", err)
+			continue
+		}
+		rendered := &myImg.Picture{}
+		if err = annotation.Render(pic, rendered, &img.Annotations); err != nil {
+			return err
+		}
+		base := filepath.Base(img.File)
+		dst := filepath.Join(*out, strings.TrimSuffix(base, filepath.Ext(base))+".jpg")
+		if err = rendered.Save(dst); err != nil {
+			return err
+		}
+		fmt.Println(dst)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"sort"
+
+	myImg "day01/minitools/myimage"
+)
+
+type dedupeFile struct {
+	Path     string `json:"path"`
+	Hash     string `json:"hash"`
+	Distance int    `json:"distance"` // 与组内第一张图的距离
+}
+
+type dedupeReport struct {
+	Method    string         `json:"method"`
+	Threshold int            `json:"threshold"`
+	Scanned   int            `json:"scanned"`
+	Groups    [][]dedupeFile `json:"groups"`
+	Errors    []string       `json:"errors,omitempty"`
+}
+
+// runDedupe minitools img dedupe [-hash phash] [-threshold 10] dir
+func runDedupe(args []string) error {
+	fs := flag.NewFlagSet("img dedupe", flag.ExitOnError)
+	method := fs.String("hash", "phash", "hash method: ahash, dhash, phash or whash")
+	threshold := fs.Int("threshold", 10, "max hamming distance between near-duplicates")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: minitools img dedupe [-hash phash] [-threshold 10] dir")
+	}
+	hm, err := myImg.ParseHashMethod(*method)
+	if err != nil {
+		return err
+	}
+
+	files, err := listImages(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	report := dedupeReport{Method: *method, Threshold: *threshold, Groups: [][]dedupeFile{}}
+
+	var paths []string
+	var hashes []myImg.ImageHash
+	tree := &myImg.BKTree{}
+	for _, path := range files {
+		pic, err := loadPicture(path)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		h, err := pic.Hash(hm)
+		if err != nil {
+			report.Errors = append(report.Errors, path+": "+err.Error())
+			continue
+		}
+		tree.Add(h, len(paths))
+		paths = append(paths, path)
+		hashes = append(hashes, h)
+	}
+	report.Scanned = len(paths)
+
+	// 并查集: 距离不超过阈值的图片归为一组
+	parent := make([]int, len(paths))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, h := range hashes {
+		for _, j := range tree.Search(h, *threshold) {
+			if a, b := find(i), find(j); a != b {
+				if a < b {
+					parent[b] = a
+				} else {
+					parent[a] = b
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range paths {
+		r := find(i)
+		groups[r] = append(groups[r], i)
+	}
+	roots := make([]int, 0, len(groups))
+	for r, members := range groups {
+		if len(members) > 1 {
+			roots = append(roots, r)
+		}
+	}
+	sort.Ints(roots)
+	for _, r := range roots {
+		members := groups[r]
+		group := make([]dedupeFile, 0, len(members))
+		for _, i := range members {
+			group = append(group, dedupeFile{paths[i], hashes[i].String(), hashes[members[0]].Distance(hashes[i])})
+		}
+		report.Groups = append(report.Groups, group)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
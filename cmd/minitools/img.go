@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	myImg "day01/minitools/myimage"
+)
+
+// runImg img 子命令
+func runImg(args []string) error {
+	if len(args) == 0 {
+		usage()
+		return errors.New("missing img subcommand")
+	}
+	switch args[0] {
+	case "dedupe":
+		return runDedupe(args[1:])
+	case "annotate":
+		return runAnnotate(args[1:])
+	case "montage":
+		return runMontage(args[1:])
+	default:
+		return fmt.Errorf("unknown img subcommand %q", args[0])
+	}
+}
+
+// isImageFile 按扩展名判断是否为支持的图片
+func isImageFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// loadPicture 加载任意已注册格式的图片, 与 LoadImg 一样读取 png 的 sRGB / gAMA 块
+func loadPicture(path string) (*myImg.Picture, error) {
+	p := &myImg.Picture{ImgPath: path}
+	if err := p.LoadImg(); err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// listImages 递归列出目录下的图片文件
+func listImages(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isImageFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
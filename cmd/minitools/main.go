@@ -0,0 +1,43 @@
+// minitools 命令行工具
+//
+//	minitools img dedupe [-hash phash] [-threshold 10] dir
+//	minitools img annotate -format yolo|coco|voc -labels path [-classes file] [-out dir] imageDir
+//	minitools img montage [-cols n] [-per-page n] [-width 200] [-fit contain] [-masonry] [-captions] [-out montage.jpg] file|dir...
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: minitools <command> [arguments]
+
+commands:
+  img dedupe    查找目录中的近似重复图片, 以 JSON 输出
+  img annotate  把 YOLO / COCO / VOC 标注画到图片上, 便于人工检查
+  img montage   把多张图片拼成网格或瀑布流审阅页, 可加文件名标题并分页`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "img":
+		err = runImg(os.Args[2:])
+	case "-h", "-help", "help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "minitools:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	myImg "day01/minitools/myimage"
+)
+
+// runMontage minitools img montage [-cols 0] [-per-page 0] [-width 200] [-height 0] [-fit contain] [-masonry] [-captions] [-out montage.jpg] path...
+func runMontage(args []string) error {
+	fs := flag.NewFlagSet("img montage", flag.ExitOnError)
+	cols := fs.Int("cols", 0, "columns per sheet, 0 for a square-ish grid")
+	perPage := fs.Int("per-page", 0, "max images per sheet, 0 for a single sheet")
+	width := fs.Int("width", 200, "cell width")
+	height := fs.Int("height", 0, "cell height, 0 for square cells")
+	spacing := fs.Int("spacing", 4, "spacing between cells")
+	bg := fs.String("bg", "ffffff", "background colour as hex rrggbb")
+	fit := fs.String("fit", "contain", "fit mode: contain, cover, stretch or none")
+	masonry := fs.Bool("masonry", false, "masonry layout instead of a grid")
+	captions := fs.Bool("captions", false, "write the file name under each image")
+	out := fs.String("out", "montage.jpg", "output file; pages are numbered when there are several")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: minitools img montage [-cols n] [-per-page n] [-width 200] [-height 0] [-fit contain] [-masonry] [-captions] [-out montage.jpg] file|dir...")
+	}
+	fm, err := myImg.ParseFitMode(*fit)
+	if err != nil {
+		return err
+	}
+	background, err := parseHexColor(*bg)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		found, err := listImages(arg)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	var pics []*myImg.Picture
+	var names []string
+	for _, path := range files {
+		pic, err := loadPicture(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "minitools:", err)
+			continue
+		}
+		pics = append(pics, pic)
+		names = append(names, filepath.Base(path))
+	}
+	if len(pics) == 0 {
+		return errors.New("no images found")
+	}
+
+	opts := myImg.MontageOptions{
+		Columns:    *cols,
+		PerPage:    *perPage,
+		CellWidth:  *width,
+		CellHeight: *height,
+		Spacing:    *spacing,
+		Background: background,
+		Fit:        fm,
+		Masonry:    *masonry,
+	}
+	if *captions {
+		opts.Captions = names
+	}
+	pages, err := myImg.Montage(pics, opts)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(*out)
+	for i, page := range pages {
+		dst := *out
+		if len(pages) > 1 {
+			dst = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(*out, ext), i+1, ext)
+		}
+		if err = page.Save(dst); err != nil {
+			return err
+		}
+		fmt.Println(dst)
+	}
+	return nil
+}
+
+// parseHexColor 解析 rrggbb 或 #rrggbb
+func parseHexColor(s string) (color.Color, error) {
+	s = strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return nil, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
@@ -0,0 +1,239 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+动画 GIF: 读取全部帧并按处置方式合成为完整画面, 对每一帧执行图片操作后重新编码
+*/
+
+// Frame 动画的一帧, Picture 为合成后的完整画面(与画布同样大小)
+type Frame struct {
+	Picture  *Picture
+	Delay    int  // 显示时间, 单位 1/100 秒
+	Disposal byte // 原文件中的处置方式(gif.DisposalNone 等), 仅供参考, 编码时重新设置
+}
+
+// Animation 多帧动画
+type Animation struct {
+	Frames    []Frame
+	LoopCount int // 0 为无限循环, -1 为只播放一次, n 为播放 n+1 次(与 image/gif 相同)
+}
+
+// NewAnimation 由同样大小的多张图片生成动画, 每帧显示 delay(1/100 秒)
+func NewAnimation(pics []*Picture, delay int) (*Animation, error) {
+	if len(pics) == 0 {
+		return nil, errors.New("no pictures")
+	}
+	a := &Animation{}
+	for _, p := range pics {
+		if p == nil || p.Img == nil {
+			return nil, errors.New("image not loaded")
+		}
+		a.Frames = append(a.Frames, Frame{Picture: p, Delay: delay})
+	}
+	if _, _, err := a.size(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadGIF 加载 GIF 的全部帧
+func LoadGIF(path string) (*Animation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeGIF(f)
+}
+
+// DecodeGIF 解码 GIF 的全部帧; 每帧按前面各帧的处置方式合成为完整画面
+func DecodeGIF(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	w, h := g.Config.Width, g.Config.Height
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	a := &Animation{LoopCount: g.LoopCount}
+	for i, frame := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var saved *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			saved = ToRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		a.Frames = append(a.Frames, Frame{Picture: &Picture{Img: ToRGBA(canvas)}, Delay: delay, Disposal: disposal})
+
+		// 处置: 为下一帧准备画布
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+	return a, nil
+}
+
+// size 画布大小, 各帧大小不同时返回错误
+func (a *Animation) size() (w, h int, err error) {
+	if len(a.Frames) == 0 {
+		return 0, 0, errors.New("animation has no frames")
+	}
+	for i, f := range a.Frames {
+		if f.Picture == nil || f.Picture.Img == nil {
+			return 0, 0, errors.New("image not loaded")
+		}
+		fw, fh := f.Picture.GetSize()
+		if i == 0 {
+			w, h = fw, fh
+		} else if fw != w || fh != h {
+			return 0, 0, fmt.Errorf("frame %d is %dx%d, want %dx%d", i, fw, fh, w, h)
+		}
+	}
+	return
+}
+
+// GetSize 画布的宽和高(第一帧的大小)
+func (a *Animation) GetSize() (int, int) {
+	if len(a.Frames) == 0 {
+		return 0, 0
+	}
+	return a.Frames[0].Picture.GetSize()
+}
+
+// Apply 对每一帧执行 fn 并返回新的动画, 帧延时保持不变; fn 的参数与 Picture 的方法一致, 如
+//
+//	a.Apply(func(p, p1 *myImg.Picture) error { return p.Resize(p1, 200, 200, "bilinear") })
+func (a *Animation) Apply(fn func(p, p1 *Picture) error) (*Animation, error) {
+	out := &Animation{LoopCount: a.LoopCount, Frames: make([]Frame, len(a.Frames))}
+	for i, f := range a.Frames {
+		p1 := &Picture{ImgPath: f.Picture.ImgPath}
+		if err := fn(f.Picture, p1); err != nil {
+			return nil, fmt.Errorf("frame %d: %v", i, err)
+		}
+		if p1.Img == nil {
+			return nil, fmt.Errorf("frame %d: operation produced no image", i)
+		}
+		out.Frames[i] = Frame{Picture: p1, Delay: f.Delay, Disposal: f.Disposal}
+	}
+	return out, nil
+}
+
+// PaletteMode 编码 GIF 时调色板的使用方式
+type PaletteMode uint8
+
+const (
+	PaletteShared   PaletteMode = iota // 所有帧共用一个全局调色板, 文件小, 帧间颜色稳定
+	PalettePerFrame                    // 每帧单独量化, 颜色更准确
+)
+
+// GIFOptions GIF 编码选项
+type GIFOptions struct {
+	Palette  PaletteMode
+	Colors   int            // 调色板颜色数(2~256), 0 时为 256; 有透明像素时含一个透明色
+	Quantize QuantizeMethod // 生成调色板的量化方法
+	Dither   DitherOptions  // 映射到调色板时的抖动方式
+}
+
+// EncodeGIF 编码为 GIF; 每帧都是完整画面, 以 DisposalBackground 写出, 透明区域不会残留前一帧
+func (a *Animation) EncodeGIF(w io.Writer, opts GIFOptions) error {
+	width, height, err := a.size()
+	if err != nil {
+		return err
+	}
+	n := opts.Colors
+	if n == 0 {
+		n = 256
+	}
+	if n < 2 || n > 256 {
+		return errors.New("palette size must be between 2 and 256")
+	}
+
+	var shared color.Palette
+	if opts.Palette == PaletteShared {
+		var all []colorBin
+		anyTransparent := false
+		for _, f := range a.Frames {
+			bins, transparent := colorHistogram(f.Picture.Img)
+			all = append(all, bins...)
+			anyTransparent = anyTransparent || transparent
+		}
+		if shared, err = paletteFromBins(all, anyTransparent, opts.Quantize, n); err != nil {
+			return err
+		}
+	}
+
+	g := &gif.GIF{LoopCount: a.LoopCount, Config: image.Config{Width: width, Height: height}}
+	if shared != nil {
+		g.Config.ColorModel = shared
+	}
+	for i, f := range a.Frames {
+		palette := shared
+		if palette == nil {
+			bins, transparent := colorHistogram(f.Picture.Img)
+			if palette, err = paletteFromBins(bins, transparent, opts.Quantize, n); err != nil {
+				return err
+			}
+		}
+		p1 := &Picture{}
+		if err = f.Picture.Dither(p1, palette, opts.Dither); err != nil {
+			return fmt.Errorf("frame %d: %v", i, err)
+		}
+		g.Image = append(g.Image, p1.Img.(*image.Paletted))
+		g.Delay = append(g.Delay, f.Delay)
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// SaveGIF 保存为 GIF 文件
+func (a *Animation) SaveGIF(path string, opts GIFOptions) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	if err = a.EncodeGIF(f, opts); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// ExtractFrames 把每一帧保存到 dir 下(frame_000.png 等), 返回文件路径
+func (a *Animation) ExtractFrames(dir string, format ImageFormat) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ext := format.String()
+	if format == FormatJPEG {
+		ext = "jpg"
+	}
+	var paths []string
+	for i, f := range a.Frames {
+		path := filepath.Join(dir, fmt.Sprintf("frame_%03d.%s", i, ext))
+		if err := f.Picture.Save(path); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,228 @@
+// Package annotation 与图片关联的目标检测标注(矩形框、多边形、关键点)
+//
+// 坐标均为像素坐标, 原点在图片左上角像素的左上角, 即像素 (x, y) 覆盖 [x, x+1) x [y, y+1)
+package annotation
+
+import (
+	"image"
+	"math"
+
+	myImg "day01/minitools/myimage"
+)
+
+// Box 矩形框 [X0, X1) x [Y0, Y1)
+type Box struct {
+	Label string
+	X0    float64
+	Y0    float64
+	X1    float64
+	Y1    float64
+}
+
+// Width 宽
+func (b Box) Width() float64 {
+	return b.X1 - b.X0
+}
+
+// Height 高
+func (b Box) Height() float64 {
+	return b.Y1 - b.Y0
+}
+
+// Area 面积, 无效的框为 0
+func (b Box) Area() float64 {
+	if b.X1 <= b.X0 || b.Y1 <= b.Y0 {
+		return 0
+	}
+	return (b.X1 - b.X0) * (b.Y1 - b.Y0)
+}
+
+// Polygon 多边形
+type Polygon struct {
+	Label  string
+	Points []myImg.PointF
+}
+
+// Bounds 多边形的外接矩形
+func (p Polygon) Bounds() Box {
+	b := Box{p.Label, math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, pt := range p.Points {
+		b.X0 = math.Min(b.X0, pt.X)
+		b.Y0 = math.Min(b.Y0, pt.Y)
+		b.X1 = math.Max(b.X1, pt.X)
+		b.Y1 = math.Max(b.Y1, pt.Y)
+	}
+	return b
+}
+
+// Keypoint 关键点, 移出画面后 Visible 为 false
+type Keypoint struct {
+	Label   string
+	X       float64
+	Y       float64
+	Visible bool
+}
+
+// Annotations 一张图片上的全部标注
+type Annotations struct {
+	Boxes     []Box
+	Polygons  []Polygon
+	Keypoints []Keypoint
+}
+
+// Clone 深拷贝
+func (a *Annotations) Clone() *Annotations {
+	if a == nil {
+		return nil
+	}
+	c := &Annotations{
+		Boxes:     append([]Box(nil), a.Boxes...),
+		Polygons:  make([]Polygon, len(a.Polygons)),
+		Keypoints: append([]Keypoint(nil), a.Keypoints...),
+	}
+	for i, p := range a.Polygons {
+		c.Polygons[i] = Polygon{p.Label, append([]myImg.PointF(nil), p.Points...)}
+	}
+	return c
+}
+
+// Transform 用单应性矩阵变换全部标注; 矩形框取变换后四个角的外接矩形
+func (a *Annotations) Transform(m myImg.Homography) {
+	if a == nil {
+		return
+	}
+	for i, b := range a.Boxes {
+		xs := [4]float64{b.X0, b.X1, b.X1, b.X0}
+		ys := [4]float64{b.Y0, b.Y0, b.Y1, b.Y1}
+		nb := Box{b.Label, math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+		for k := range xs {
+			x, y := m.Apply(xs[k], ys[k])
+			nb.X0 = math.Min(nb.X0, x)
+			nb.Y0 = math.Min(nb.Y0, y)
+			nb.X1 = math.Max(nb.X1, x)
+			nb.Y1 = math.Max(nb.Y1, y)
+		}
+		a.Boxes[i] = nb
+	}
+	for _, p := range a.Polygons {
+		for k, pt := range p.Points {
+			p.Points[k].X, p.Points[k].Y = m.Apply(pt.X, pt.Y)
+		}
+	}
+	for i, kp := range a.Keypoints {
+		a.Keypoints[i].X, a.Keypoints[i].Y = m.Apply(kp.X, kp.Y)
+	}
+}
+
+// Clip 把标注裁剪到矩形 r 内
+// 裁剪后保留面积不足原面积 minVisibility(0~1) 的框和多边形会被删除; r 外的关键点标为不可见
+func (a *Annotations) Clip(r image.Rectangle, minVisibility float64) {
+	a.ClipCumulative(r, minVisibility, nil, nil)
+}
+
+// ClipCumulative 同 Clip, 用于连续多次裁剪: boxVis / polyVis 为各框、多边形此前累计保留的面积比例(nil 或长度不符时均为 1),
+// 本次保留的比例与之相乘后再和 minVisibility 比较; 返回留下的各项的累计比例, 供下一次调用
+func (a *Annotations) ClipCumulative(r image.Rectangle, minVisibility float64, boxVis, polyVis []float64) ([]float64, []float64) {
+	if a == nil {
+		return nil, nil
+	}
+	if len(boxVis) != len(a.Boxes) {
+		boxVis = nil
+	}
+	if len(polyVis) != len(a.Polygons) {
+		polyVis = nil
+	}
+	x0, y0, x1, y1 := float64(r.Min.X), float64(r.Min.Y), float64(r.Max.X), float64(r.Max.Y)
+	prev := func(vis []float64, i int) float64 {
+		if vis == nil {
+			return 1
+		}
+		return vis[i]
+	}
+
+	boxes := a.Boxes[:0]
+	newBoxVis := make([]float64, 0, len(a.Boxes))
+	for i, b := range a.Boxes {
+		area := b.Area()
+		c := Box{b.Label, math.Max(b.X0, x0), math.Max(b.Y0, y0), math.Min(b.X1, x1), math.Min(b.Y1, y1)}
+		if area > 0 && c.Area() > 0 {
+			if v := prev(boxVis, i) * c.Area() / area; v >= minVisibility {
+				boxes = append(boxes, c)
+				newBoxVis = append(newBoxVis, v)
+			}
+		}
+	}
+	a.Boxes = boxes
+
+	polys := a.Polygons[:0]
+	newPolyVis := make([]float64, 0, len(a.Polygons))
+	for i, p := range a.Polygons {
+		area := polygonArea(p.Points)
+		c := Polygon{p.Label, clipPolygon(p.Points, x0, y0, x1, y1)}
+		if ca := polygonArea(c.Points); len(c.Points) >= 3 && area > 0 && ca > 0 {
+			if v := prev(polyVis, i) * ca / area; v >= minVisibility {
+				polys = append(polys, c)
+				newPolyVis = append(newPolyVis, v)
+			}
+		}
+	}
+	a.Polygons = polys
+
+	for i, kp := range a.Keypoints {
+		if kp.X < x0 || kp.X >= x1 || kp.Y < y0 || kp.Y >= y1 {
+			a.Keypoints[i].Visible = false
+		}
+	}
+	return newBoxVis, newPolyVis
+}
+
+// polygonArea 多边形面积(鞋带公式)
+func polygonArea(pts []myImg.PointF) float64 {
+	var s float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		s += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return math.Abs(s) / 2
+}
+
+// clipPolygon Sutherland-Hodgman 算法把多边形裁剪到矩形内
+func clipPolygon(pts []myImg.PointF, x0, y0, x1, y1 float64) []myImg.PointF {
+	type edge struct {
+		inside func(p myImg.PointF) bool
+		cross  func(a, b myImg.PointF) myImg.PointF
+	}
+	atX := func(a, b myImg.PointF, x float64) myImg.PointF {
+		return myImg.PointF{X: x, Y: a.Y + (b.Y-a.Y)*(x-a.X)/(b.X-a.X)}
+	}
+	atY := func(a, b myImg.PointF, y float64) myImg.PointF {
+		return myImg.PointF{X: a.X + (b.X-a.X)*(y-a.Y)/(b.Y-a.Y), Y: y}
+	}
+	edges := []edge{
+		{func(p myImg.PointF) bool { return p.X >= x0 }, func(a, b myImg.PointF) myImg.PointF { return atX(a, b, x0) }},
+		{func(p myImg.PointF) bool { return p.X <= x1 }, func(a, b myImg.PointF) myImg.PointF { return atX(a, b, x1) }},
+		{func(p myImg.PointF) bool { return p.Y >= y0 }, func(a, b myImg.PointF) myImg.PointF { return atY(a, b, y0) }},
+		{func(p myImg.PointF) bool { return p.Y <= y1 }, func(a, b myImg.PointF) myImg.PointF { return atY(a, b, y1) }},
+	}
+	out := pts
+	for _, e := range edges {
+		if len(out) == 0 {
+			break
+		}
+		in := out
+		out = nil
+		prev := in[len(in)-1]
+		for _, cur := range in {
+			if e.inside(cur) {
+				if !e.inside(prev) {
+					out = append(out, e.cross(prev, cur))
+				}
+				out = append(out, cur)
+			} else if e.inside(prev) {
+				out = append(out, e.cross(prev, cur))
+			}
+			prev = cur
+		}
+	}
+	return out
+}
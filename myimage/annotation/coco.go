@@ -0,0 +1,216 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	myImg "day01/minitools/myimage"
+)
+
+/*
+COCO 格式: 一个 JSON 文件描述整个数据集
+  bbox 为 [x, y, w, h]; segmentation 为多边形 [[x1, y1, x2, y2, ...]]; keypoints 为 [x, y, v, ...]
+读入时带多边形分割的目标转成 Polygon, 只有 bbox 的转成 Box; 关键点按类别中的关键点名字命名
+*/
+
+type cocoFile struct {
+	Images      []cocoImage      `json:"images"`
+	Annotations []cocoAnnotation `json:"annotations"`
+	Categories  []cocoCategory   `json:"categories"`
+}
+
+type cocoImage struct {
+	ID       int    `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type cocoAnnotation struct {
+	ID           int             `json:"id"`
+	ImageID      int             `json:"image_id"`
+	CategoryID   int             `json:"category_id"`
+	BBox         []float64       `json:"bbox"`
+	Segmentation json.RawMessage `json:"segmentation,omitempty"`
+	Area         float64         `json:"area"`
+	IsCrowd      int             `json:"iscrowd"`
+	Keypoints    []float64       `json:"keypoints,omitempty"`
+	NumKeypoints int             `json:"num_keypoints,omitempty"`
+}
+
+type cocoCategory struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Keypoints []string `json:"keypoints,omitempty"`
+}
+
+// cocoKeypointCategory 写出关键点时使用的类别名
+const cocoKeypointCategory = "keypoints"
+
+// ReadCOCO 读取 COCO JSON
+func ReadCOCO(r io.Reader) (*Dataset, error) {
+	var f cocoFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	d := &Dataset{}
+	cats := make(map[int]cocoCategory)
+	for _, c := range f.Categories {
+		cats[c.ID] = c
+		d.CategoryID(c.Name)
+	}
+	images := make(map[int]*Image)
+	for _, ci := range f.Images {
+		img := &Image{File: ci.FileName, Width: ci.Width, Height: ci.Height}
+		images[ci.ID] = img
+		d.Images = append(d.Images, img)
+	}
+
+	for _, a := range f.Annotations {
+		img, ok := images[a.ImageID]
+		if !ok {
+			return nil, fmt.Errorf("coco annotation %d: unknown image %d", a.ID, a.ImageID)
+		}
+		cat, ok := cats[a.CategoryID]
+		if !ok {
+			return nil, fmt.Errorf("coco annotation %d: unknown category %d", a.ID, a.CategoryID)
+		}
+
+		// 关键点
+		for k := 0; k+2 < len(a.Keypoints); k += 3 {
+			v := a.Keypoints[k+2]
+			if v == 0 && a.Keypoints[k] == 0 && a.Keypoints[k+1] == 0 {
+				continue
+			}
+			name := fmt.Sprintf("%s_%d", cat.Name, k/3)
+			if k/3 < len(cat.Keypoints) {
+				name = cat.Keypoints[k/3]
+			}
+			img.Keypoints = append(img.Keypoints, Keypoint{name, a.Keypoints[k], a.Keypoints[k+1], v > 0})
+		}
+		if len(a.Keypoints) > 0 && cat.Name == cocoKeypointCategory {
+			continue
+		}
+
+		// 多边形分割(RLE 格式的分割不支持, 退化为 bbox)
+		var polys [][]float64
+		if len(a.Segmentation) > 0 && a.Segmentation[0] == '[' {
+			if err := json.Unmarshal(a.Segmentation, &polys); err != nil {
+				return nil, fmt.Errorf("coco annotation %d: %v", a.ID, err)
+			}
+		}
+		added := false
+		for _, seg := range polys {
+			if len(seg) < 6 {
+				continue
+			}
+			p := Polygon{Label: cat.Name}
+			for k := 0; k+1 < len(seg); k += 2 {
+				p.Points = append(p.Points, myImg.PointF{X: seg[k], Y: seg[k+1]})
+			}
+			img.Polygons = append(img.Polygons, p)
+			added = true
+		}
+		if !added && len(a.BBox) == 4 {
+			b := a.BBox
+			img.Boxes = append(img.Boxes, Box{cat.Name, b[0], b[1], b[0] + b[2], b[1] + b[3]})
+		}
+	}
+	return d, nil
+}
+
+// WriteCOCO 写出 COCO JSON; 类别号从 1 开始, 每张图片的关键点合成一个 "keypoints" 类别的目标
+func WriteCOCO(w io.Writer, d *Dataset) error {
+	f := cocoFile{Images: []cocoImage{}, Annotations: []cocoAnnotation{}, Categories: []cocoCategory{}}
+
+	// 先确定所有类别
+	var kpNames []string
+	kpIndex := make(map[string]int)
+	for _, img := range d.Images {
+		for _, b := range img.Boxes {
+			d.CategoryID(b.Label)
+		}
+		for _, p := range img.Polygons {
+			d.CategoryID(p.Label)
+		}
+		for _, kp := range img.Keypoints {
+			if _, ok := kpIndex[kp.Label]; !ok {
+				kpIndex[kp.Label] = len(kpNames)
+				kpNames = append(kpNames, kp.Label)
+			}
+		}
+	}
+	kpCat := -1
+	if len(kpNames) > 0 {
+		kpCat = d.CategoryID(cocoKeypointCategory)
+	}
+	for i, name := range d.Categories {
+		c := cocoCategory{ID: i + 1, Name: name}
+		if i == kpCat {
+			c.Keypoints = kpNames
+		}
+		f.Categories = append(f.Categories, c)
+	}
+
+	nextID := 1
+	add := func(a cocoAnnotation) {
+		a.ID = nextID
+		nextID++
+		f.Annotations = append(f.Annotations, a)
+	}
+	for i, img := range d.Images {
+		imageID := i + 1
+		f.Images = append(f.Images, cocoImage{imageID, img.File, img.Width, img.Height})
+		for _, b := range img.Boxes {
+			add(cocoAnnotation{
+				ImageID:    imageID,
+				CategoryID: d.CategoryID(b.Label) + 1,
+				BBox:       []float64{b.X0, b.Y0, b.Width(), b.Height()},
+				Area:       b.Area(),
+			})
+		}
+		for _, p := range img.Polygons {
+			seg := make([]float64, 0, 2*len(p.Points))
+			for _, pt := range p.Points {
+				seg = append(seg, pt.X, pt.Y)
+			}
+			raw, _ := json.Marshal([][]float64{seg})
+			b := p.Bounds()
+			add(cocoAnnotation{
+				ImageID:      imageID,
+				CategoryID:   d.CategoryID(p.Label) + 1,
+				BBox:         []float64{b.X0, b.Y0, b.Width(), b.Height()},
+				Segmentation: raw,
+				Area:         polygonArea(p.Points),
+			})
+		}
+		if len(img.Keypoints) > 0 {
+			kps := make([]float64, 3*len(kpNames))
+			n := 0
+			b := Box{"", math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+			for _, kp := range img.Keypoints {
+				k := kpIndex[kp.Label]
+				kps[3*k], kps[3*k+1], kps[3*k+2] = kp.X, kp.Y, 1
+				if kp.Visible {
+					kps[3*k+2] = 2
+					n++
+				}
+				b.X0, b.Y0 = math.Min(b.X0, kp.X), math.Min(b.Y0, kp.Y)
+				b.X1, b.Y1 = math.Max(b.X1, kp.X), math.Max(b.Y1, kp.Y)
+			}
+			add(cocoAnnotation{
+				ImageID:      imageID,
+				CategoryID:   kpCat + 1,
+				BBox:         []float64{b.X0, b.Y0, b.Width(), b.Height()},
+				Keypoints:    kps,
+				NumKeypoints: n,
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
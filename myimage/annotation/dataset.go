@@ -0,0 +1,76 @@
+package annotation
+
+import (
+	"errors"
+	"image"
+	_ "image/gif"  // 注册 gif 解码, 读取图片尺寸用
+	_ "image/jpeg" // 注册 jpeg 解码
+	_ "image/png"  // 注册 png 解码
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Image 一张图片及其标注
+type Image struct {
+	File   string // 图片文件名(相对于图片目录)
+	Width  int
+	Height int
+	Annotations
+}
+
+// Dataset 数据集: 类别列表和全部图片, 各种格式读入后都转换为这个结构
+type Dataset struct {
+	Categories []string
+	Images     []*Image
+}
+
+// CategoryID 类别的下标, 不存在时追加到 Categories
+func (d *Dataset) CategoryID(label string) int {
+	for i, c := range d.Categories {
+		if c == label {
+			return i
+		}
+	}
+	d.Categories = append(d.Categories, label)
+	return len(d.Categories) - 1
+}
+
+// Find 按文件名查找图片, 找不到返回 nil
+func (d *Dataset) Find(file string) *Image {
+	for _, img := range d.Images {
+		if img.File == file {
+			return img
+		}
+	}
+	return nil
+}
+
+// imageSize 只读取图片头获取宽高
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, errors.New(path + ": " + err.Error())
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// isImageFile 按扩展名判断是否为图片
+func isImageFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// stem 去掉目录和扩展名的文件名
+func stem(name string) string {
+	base := filepath.Base(name)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
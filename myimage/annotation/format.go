@@ -0,0 +1,65 @@
+package annotation
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Format 标注格式
+type Format string
+
+const (
+	FormatYOLO Format = "yolo"
+	FormatCOCO Format = "coco"
+	FormatVOC  Format = "voc"
+)
+
+// ParseFormat 从名字解析格式
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatYOLO, FormatCOCO, FormatVOC:
+		return f, nil
+	}
+	return "", fmt.Errorf("annotation format only yolo, coco or voc, got %q", name)
+}
+
+// Load 按格式读取数据集
+// YOLO: path 为标注目录, 需要 imageDir 读取图片尺寸和 classes; COCO: path 为 JSON 文件; VOC: path 为 XML 目录
+func Load(format Format, path, imageDir string, classes []string) (*Dataset, error) {
+	switch format {
+	case FormatYOLO:
+		return ReadYOLODataset(imageDir, path, classes)
+	case FormatCOCO:
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ReadCOCO(f)
+	case FormatVOC:
+		return ReadVOCDir(path)
+	}
+	return nil, fmt.Errorf("unknown annotation format %q", format)
+}
+
+// Save 按格式写出数据集, path 的含义同 Load; 配合 Load 即可在格式之间转换
+func Save(format Format, path string, d *Dataset) error {
+	switch format {
+	case FormatYOLO:
+		return WriteYOLODataset(path, d)
+	case FormatCOCO:
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err = WriteCOCO(f, d); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case FormatVOC:
+		return WriteVOCDir(path, d)
+	}
+	return fmt.Errorf("unknown annotation format %q", format)
+}
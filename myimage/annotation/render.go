@@ -0,0 +1,81 @@
+package annotation
+
+import (
+	"errors"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math"
+
+	myImg "day01/minitools/myimage"
+)
+
+// labelColors 标签颜色表
+var labelColors = []color.RGBA{
+	{230, 25, 75, 255}, {60, 180, 75, 255}, {255, 225, 25, 255}, {0, 130, 200, 255},
+	{245, 130, 48, 255}, {145, 30, 180, 255}, {70, 240, 240, 255}, {240, 50, 230, 255},
+	{210, 245, 60, 255}, {250, 190, 190, 255}, {0, 128, 128, 255}, {170, 110, 40, 255},
+}
+
+// LabelColor 标签对应的颜色, 同一标签总是相同的颜色
+func LabelColor(label string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(label))
+	return labelColors[h.Sum32()%uint32(len(labelColors))]
+}
+
+// Render 把标注画到图片上(矩形框、多边形轮廓、关键点和标签文字), 结果写入 p1; ann 为 nil 时只复制图片
+func Render(p, p1 *myImg.Picture, ann *Annotations) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if ann == nil {
+		ann = &Annotations{}
+	}
+	dst := myImg.ToRGBA(p.Img)
+	w, h := dst.Bounds().Dx(), dst.Bounds().Dy()
+	thickness := int(math.Max(1, math.Round(float64(w+h)/600)))
+	scale := int(math.Max(1, math.Round(float64(w+h)/800)))
+
+	label := func(text string, x, y int, c color.Color) {
+		_, th := myImg.TextSize(text, scale)
+		// 标签画在框的上方, 放不下时画在框内
+		ly := y - th - 2*scale
+		if ly < 0 {
+			ly = y
+		}
+		myImg.DrawLabel(dst, x, ly, text, c, scale)
+	}
+	round := func(v float64) int { return int(math.Round(v)) }
+
+	for _, b := range ann.Boxes {
+		c := LabelColor(b.Label)
+		myImg.DrawRect(dst, image.Rect(round(b.X0), round(b.Y0), round(b.X1), round(b.Y1)), c, thickness)
+		label(b.Label, round(b.X0), round(b.Y0), c)
+	}
+	for _, poly := range ann.Polygons {
+		if len(poly.Points) == 0 {
+			continue
+		}
+		c := LabelColor(poly.Label)
+		prev := poly.Points[len(poly.Points)-1]
+		for _, pt := range poly.Points {
+			myImg.DrawLine(dst, round(prev.X), round(prev.Y), round(pt.X), round(pt.Y), c, thickness)
+			prev = pt
+		}
+		bb := poly.Bounds()
+		label(poly.Label, round(bb.X0), round(bb.Y0), c)
+	}
+	for _, kp := range ann.Keypoints {
+		if !kp.Visible {
+			continue
+		}
+		c := LabelColor(kp.Label)
+		r := 2 * thickness
+		x, y := round(kp.X), round(kp.Y)
+		myImg.FillRect(dst, image.Rect(x-r, y-r, x+r+1, y+r+1), c)
+	}
+
+	p1.Img = dst
+	return
+}
@@ -0,0 +1,142 @@
+package annotation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Pascal VOC 格式: 每张图片一个 XML, 只支持矩形框
+坐标为从 1 开始的像素下标且包含右下角像素, 即 xmin = X0 + 1, xmax = X1
+*/
+
+type vocAnnotation struct {
+	XMLName  xml.Name    `xml:"annotation"`
+	Folder   string      `xml:"folder,omitempty"`
+	Filename string      `xml:"filename"`
+	Size     vocSize     `xml:"size"`
+	Objects  []vocObject `xml:"object"`
+}
+
+type vocSize struct {
+	Width  int `xml:"width"`
+	Height int `xml:"height"`
+	Depth  int `xml:"depth"`
+}
+
+type vocObject struct {
+	Name      string    `xml:"name"`
+	Pose      string    `xml:"pose"`
+	Truncated int       `xml:"truncated"`
+	Difficult int       `xml:"difficult"`
+	BndBox    vocBndBox `xml:"bndbox"`
+}
+
+type vocBndBox struct {
+	XMin float64 `xml:"xmin"`
+	YMin float64 `xml:"ymin"`
+	XMax float64 `xml:"xmax"`
+	YMax float64 `xml:"ymax"`
+}
+
+// ReadVOC 读取一个 VOC XML
+func ReadVOC(r io.Reader) (*Image, error) {
+	var v vocAnnotation
+	if err := xml.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+	img := &Image{File: v.Filename, Width: v.Size.Width, Height: v.Size.Height}
+	for _, o := range v.Objects {
+		b := o.BndBox
+		img.Boxes = append(img.Boxes, Box{o.Name, b.XMin - 1, b.YMin - 1, b.XMax, b.YMax})
+	}
+	return img, nil
+}
+
+// WriteVOC 写出 VOC XML; 多边形写成其外接矩形, 关键点被忽略
+func WriteVOC(w io.Writer, img *Image) error {
+	v := vocAnnotation{Filename: img.File, Size: vocSize{img.Width, img.Height, 3}}
+	boxes := append([]Box(nil), img.Boxes...)
+	for _, p := range img.Polygons {
+		boxes = append(boxes, p.Bounds())
+	}
+	for _, b := range boxes {
+		xmin, ymin := math.Round(b.X0)+1, math.Round(b.Y0)+1
+		xmax, ymax := math.Round(b.X1), math.Round(b.Y1)
+		truncated := 0
+		if b.X0 <= 0 || b.Y0 <= 0 || b.X1 >= float64(img.Width) || b.Y1 >= float64(img.Height) {
+			truncated = 1
+		}
+		v.Objects = append(v.Objects, vocObject{
+			Name:      b.Label,
+			Pose:      "Unspecified",
+			Truncated: truncated,
+			BndBox:    vocBndBox{xmin, ymin, xmax, ymax},
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadVOCDir 读取目录下全部 VOC XML
+func ReadVOCDir(dir string) (*Dataset, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dataset{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".xml") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		img, err := ReadVOC(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.Name(), err)
+		}
+		for _, b := range img.Boxes {
+			d.CategoryID(b.Label)
+		}
+		d.Images = append(d.Images, img)
+	}
+	return d, nil
+}
+
+// WriteVOCDir 把数据集写成 VOC 格式, 每张图片一个同名 XML
+func WriteVOCDir(dir string, d *Dataset) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, img := range d.Images {
+		f, err := os.Create(filepath.Join(dir, stem(img.File)+".xml"))
+		if err != nil {
+			return err
+		}
+		err = WriteVOC(f, img)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
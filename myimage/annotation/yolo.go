@@ -0,0 +1,150 @@
+package annotation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	myImg "day01/minitools/myimage"
+)
+
+/*
+YOLO 格式: 每张图片一个同名 txt, 每行一个目标, 坐标按图片宽高归一化
+  矩形框: class cx cy w h
+  多边形(分割): class x1 y1 x2 y2 ...
+*/
+
+// ReadYOLO 读取一个 YOLO 标注文件, classes 为类别名(下标即类别号), width/height 为图片大小
+func ReadYOLO(r io.Reader, classes []string, width, height int) (*Annotations, error) {
+	ann := &Annotations{}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 5 || len(fields)%2 == 0 {
+			return nil, fmt.Errorf("yolo line %d: unexpected %d fields", line, len(fields))
+		}
+		cls, err := strconv.Atoi(fields[0])
+		if err != nil || cls < 0 {
+			return nil, fmt.Errorf("yolo line %d: bad class %q", line, fields[0])
+		}
+		label := strconv.Itoa(cls)
+		if cls < len(classes) {
+			label = classes[cls]
+		}
+		vals := make([]float64, len(fields)-1)
+		for i, f := range fields[1:] {
+			if vals[i], err = strconv.ParseFloat(f, 64); err != nil {
+				return nil, fmt.Errorf("yolo line %d: %v", line, err)
+			}
+		}
+		w, h := float64(width), float64(height)
+		if len(vals) == 4 {
+			cx, cy, bw, bh := vals[0]*w, vals[1]*h, vals[2]*w, vals[3]*h
+			ann.Boxes = append(ann.Boxes, Box{label, cx - bw/2, cy - bh/2, cx + bw/2, cy + bh/2})
+			continue
+		}
+		poly := Polygon{Label: label}
+		for i := 0; i < len(vals); i += 2 {
+			poly.Points = append(poly.Points, myImg.PointF{X: vals[i] * w, Y: vals[i+1] * h})
+		}
+		ann.Polygons = append(ann.Polygons, poly)
+	}
+	return ann, sc.Err()
+}
+
+// WriteYOLO 写出 YOLO 标注, 矩形框写成 cx cy w h, 多边形写成分割格式; 关键点不支持, 会被忽略
+func WriteYOLO(w io.Writer, ann *Annotations, d *Dataset, width, height int) error {
+	bw := bufio.NewWriter(w)
+	fw, fh := float64(width), float64(height)
+	for _, b := range ann.Boxes {
+		fmt.Fprintf(bw, "%d %.6f %.6f %.6f %.6f\n", d.CategoryID(b.Label),
+			(b.X0+b.X1)/2/fw, (b.Y0+b.Y1)/2/fh, b.Width()/fw, b.Height()/fh)
+	}
+	for _, p := range ann.Polygons {
+		fmt.Fprintf(bw, "%d", d.CategoryID(p.Label))
+		for _, pt := range p.Points {
+			fmt.Fprintf(bw, " %.6f %.6f", pt.X/fw, pt.Y/fh)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// ReadClasses 读取类别文件(每行一个类别名, 如 classes.txt / obj.names)
+func ReadClasses(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var classes []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			classes = append(classes, line)
+		}
+	}
+	return classes, nil
+}
+
+// ReadYOLODataset 读取 YOLO 数据集: imageDir 下的每张图片对应 labelDir 下的同名 txt(没有则视为无目标)
+func ReadYOLODataset(imageDir, labelDir string, classes []string) (*Dataset, error) {
+	entries, err := ioutil.ReadDir(imageDir)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dataset{Categories: append([]string(nil), classes...)}
+	for _, e := range entries {
+		if e.IsDir() || !isImageFile(e.Name()) {
+			continue
+		}
+		w, h, err := imageSize(filepath.Join(imageDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		img := &Image{File: e.Name(), Width: w, Height: h}
+		f, err := os.Open(filepath.Join(labelDir, stem(e.Name())+".txt"))
+		if err == nil {
+			ann, err := ReadYOLO(f, classes, w, h)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", e.Name(), err)
+			}
+			img.Annotations = *ann
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		d.Images = append(d.Images, img)
+	}
+	return d, nil
+}
+
+// WriteYOLODataset 把数据集写成 YOLO 格式: labelDir 下每张图片一个 txt, 以及 classes.txt
+func WriteYOLODataset(labelDir string, d *Dataset) error {
+	if err := os.MkdirAll(labelDir, 0755); err != nil {
+		return err
+	}
+	for _, img := range d.Images {
+		f, err := os.Create(filepath.Join(labelDir, stem(img.File)+".txt"))
+		if err != nil {
+			return err
+		}
+		err = WriteYOLO(f, &img.Annotations, d, img.Width, img.Height)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// 写图片时可能追加了新类别, 最后再写类别文件
+	return ioutil.WriteFile(filepath.Join(labelDir, "classes.txt"), []byte(strings.Join(d.Categories, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,102 @@
+// Package augment 目标检测训练数据增强
+//
+// 每个变换按概率随机执行, 随机数全部来自 Pipeline 的种子, 相同种子得到相同结果;
+// 图片上的矩形框、多边形和关键点会随几何变换一起变换, 移出画面的部分被裁剪或删除
+//
+//	pipe := augment.NewPipeline(42,
+//		augment.Step{P: 0.5, T: augment.HorizontalFlip{}},
+//		augment.Step{P: 0.8, T: augment.Rotate{MaxAngle: 10}},
+//		augment.Step{P: 1, T: augment.ColorJitter{Brightness: 0.2, Contrast: 0.2}},
+//	)
+//	out, err := pipe.Apply(augment.Sample{Picture: pic, Ann: ann})
+package augment
+
+import (
+	"errors"
+	"image"
+	"math/rand"
+
+	myImg "day01/minitools/myimage"
+	"day01/minitools/myimage/annotation"
+)
+
+// Sample 一张图片及其标注, Ann 可以为 nil
+type Sample struct {
+	Picture *myImg.Picture
+	Ann     *annotation.Annotations
+}
+
+// size 图片的宽和高
+func (s *Sample) size() (int, int) {
+	return s.Picture.GetSize()
+}
+
+// Transform 一种增强变换, 原地修改 s
+type Transform interface {
+	Apply(s *Sample, rng *rand.Rand) error
+}
+
+// Step 流水线中的一步, 以概率 P(0~1) 执行 T
+type Step struct {
+	P float64
+	T Transform
+}
+
+// Pipeline 增强流水线
+type Pipeline struct {
+	Steps         []Step
+	MinVisibility float64 // 几何变换后保留面积不足原面积该比例的框被删除(多次裁剪累计计算), 默认 0.3
+	rng           *rand.Rand
+}
+
+// NewPipeline 创建使用指定种子的流水线
+func NewPipeline(seed int64, steps ...Step) *Pipeline {
+	return &Pipeline{Steps: steps, MinVisibility: 0.3, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Rand 流水线的随机源, 供 Mosaic 等需要额外随机数的场合使用
+func (pl *Pipeline) Rand() *rand.Rand {
+	return pl.rng
+}
+
+// Apply 依次执行各步, 返回新的样本, 输入样本不会被修改
+func (pl *Pipeline) Apply(s Sample) (Sample, error) {
+	if s.Picture == nil || s.Picture.Img == nil {
+		return s, errors.New("image not loaded")
+	}
+	out := Sample{&myImg.Picture{ImgPath: s.Picture.ImgPath, Img: s.Picture.Img, Space: s.Picture.Space}, s.Ann.Clone()}
+	// 各框、多边形累计保留的面积比例, 与变换前的原面积比较而不是上一步裁剪后的面积
+	var boxVis, polyVis []float64
+	for _, st := range pl.Steps {
+		if pl.rng.Float64() >= st.P {
+			continue
+		}
+		if err := st.T.Apply(&out, pl.rng); err != nil {
+			return s, err
+		}
+		if out.Ann != nil {
+			w, h := out.size()
+			boxVis, polyVis = out.Ann.ClipCumulative(image.Rect(0, 0, w, h), pl.MinVisibility, boxVis, polyVis)
+		}
+	}
+	return out, nil
+}
+
+// uniform [lo, hi) 上的均匀分布
+func uniform(rng *rand.Rand, lo, hi float64) float64 {
+	return lo + rng.Float64()*(hi-lo)
+}
+
+// warp 对样本做单应性变换, 输出大小为 w x h; m 作用在标注坐标(像素边界)上
+func warp(s *Sample, m myImg.Homography, w, h int) error {
+	// 图像按像素中心采样: x_idx = x - 0.5
+	toIdx := myImg.Homography{1, 0, -0.5, 0, 1, -0.5, 0, 0, 1}
+	fromIdx := myImg.Homography{1, 0, 0.5, 0, 1, 0.5, 0, 0, 1}
+	out := &myImg.Picture{ImgPath: s.Picture.ImgPath}
+	if err := s.Picture.WarpPerspective(out, toIdx.Mul(m).Mul(fromIdx), w, h); err != nil {
+		return err
+	}
+	s.Picture = out
+	s.Ann.Transform(m)
+	return nil
+}
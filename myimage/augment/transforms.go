@@ -0,0 +1,222 @@
+package augment
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+
+	myImg "day01/minitools/myimage"
+	"day01/minitools/myimage/annotation"
+)
+
+// RandomCrop 随机位置裁剪出 Width x Height 的区域; 图片更小时先等比放大
+type RandomCrop struct {
+	Width  int
+	Height int
+}
+
+// Apply 实现 Transform
+func (t RandomCrop) Apply(s *Sample, rng *rand.Rand) error {
+	if t.Width <= 0 || t.Height <= 0 {
+		return errors.New("crop size must be positive")
+	}
+	w, h := s.size()
+	scale := math.Max(1, math.Max(float64(t.Width)/float64(w), float64(t.Height)/float64(h)))
+	sw, sh := float64(w)*scale, float64(h)*scale
+	x0 := uniform(rng, 0, sw-float64(t.Width))
+	y0 := uniform(rng, 0, sh-float64(t.Height))
+	x0, y0 = math.Floor(x0), math.Floor(y0)
+	return warp(s, myImg.Homography{scale, 0, -x0, 0, scale, -y0, 0, 0, 1}, t.Width, t.Height)
+}
+
+// HorizontalFlip 水平翻转
+type HorizontalFlip struct{}
+
+// Apply 实现 Transform
+func (HorizontalFlip) Apply(s *Sample, rng *rand.Rand) error {
+	w, h := s.size()
+	return warp(s, myImg.Homography{-1, 0, float64(w), 0, 1, 0, 0, 0, 1}, w, h)
+}
+
+// VerticalFlip 垂直翻转
+type VerticalFlip struct{}
+
+// Apply 实现 Transform
+func (VerticalFlip) Apply(s *Sample, rng *rand.Rand) error {
+	w, h := s.size()
+	return warp(s, myImg.Homography{1, 0, 0, 0, -1, float64(h), 0, 0, 1}, w, h)
+}
+
+// Rotate 绕中心随机旋转 [-MaxAngle, MaxAngle] 度, 画布大小不变
+type Rotate struct {
+	MaxAngle float64
+}
+
+// Apply 实现 Transform
+func (t Rotate) Apply(s *Sample, rng *rand.Rand) error {
+	w, h := s.size()
+	a := uniform(rng, -t.MaxAngle, t.MaxAngle) * math.Pi / 180
+	cx, cy := float64(w)/2, float64(h)/2
+	c, sn := math.Cos(a), math.Sin(a)
+	// 平移到中心 -> 旋转 -> 平移回去
+	m := myImg.Homography{c, -sn, cx - c*cx + sn*cy, sn, c, cy - sn*cx - c*cy, 0, 0, 1}
+	return warp(s, m, w, h)
+}
+
+// Scale 随机缩放 [Min, Max] 倍, 输出大小随之改变
+type Scale struct {
+	Min float64
+	Max float64
+}
+
+// Apply 实现 Transform
+func (t Scale) Apply(s *Sample, rng *rand.Rand) error {
+	if t.Min <= 0 || t.Max < t.Min {
+		return errors.New("invalid scale range")
+	}
+	w, h := s.size()
+	k := uniform(rng, t.Min, t.Max)
+	nw, nh := int(math.Round(float64(w)*k)), int(math.Round(float64(h)*k))
+	if nw < 1 || nh < 1 {
+		return errors.New("scaled image is empty")
+	}
+	return warp(s, myImg.Homography{float64(nw) / float64(w), 0, 0, 0, float64(nh) / float64(h), 0, 0, 0, 1}, nw, nh)
+}
+
+// ColorJitter 随机调整颜色, 各系数在 [1-x, 1+x] 内取值, Hue 为最大色相旋转角度(度)
+type ColorJitter struct {
+	Brightness float64
+	Contrast   float64
+	Saturation float64
+	Hue        float64
+}
+
+// Apply 实现 Transform
+func (t ColorJitter) Apply(s *Sample, rng *rand.Rand) error {
+	out := &myImg.Picture{ImgPath: s.Picture.ImgPath}
+	err := s.Picture.AdjustColor(out,
+		uniform(rng, 1-t.Brightness, 1+t.Brightness),
+		uniform(rng, 1-t.Contrast, 1+t.Contrast),
+		uniform(rng, 1-t.Saturation, 1+t.Saturation),
+		uniform(rng, -t.Hue, t.Hue))
+	if err != nil {
+		return err
+	}
+	s.Picture = out
+	return nil
+}
+
+// Blur 高斯模糊, sigma 在 [0, MaxSigma] 内取值
+type Blur struct {
+	MaxSigma float64
+}
+
+// Apply 实现 Transform
+func (t Blur) Apply(s *Sample, rng *rand.Rand) error {
+	out := &myImg.Picture{ImgPath: s.Picture.ImgPath}
+	if err := s.Picture.GaussianBlur(out, uniform(rng, 0, t.MaxSigma)); err != nil {
+		return err
+	}
+	s.Picture = out
+	return nil
+}
+
+// Noise 高斯噪声, sigma 在 [0, MaxSigma] 内取值(像素值)
+type Noise struct {
+	MaxSigma   float64
+	PerChannel bool
+}
+
+// Apply 实现 Transform
+func (t Noise) Apply(s *Sample, rng *rand.Rand) error {
+	out := &myImg.Picture{ImgPath: s.Picture.ImgPath}
+	if err := s.Picture.GaussianNoise(out, rng, 0, uniform(rng, 0, t.MaxSigma), t.PerChannel); err != nil {
+		return err
+	}
+	s.Picture = out
+	return nil
+}
+
+// Cutout 随机擦除 Holes 个边长为 Size 的正方形区域, 用 Fill 填充; 标注保持不变
+type Cutout struct {
+	Holes int
+	Size  int
+	Fill  color.Color // nil 时为黑色
+}
+
+// Apply 实现 Transform
+func (t Cutout) Apply(s *Sample, rng *rand.Rand) error {
+	w, h := s.size()
+	fill := t.Fill
+	if fill == nil {
+		fill = color.Black
+	}
+	b := s.Picture.Img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), s.Picture.Img, b.Min, draw.Src)
+	for k := 0; k < t.Holes; k++ {
+		cx, cy := rng.Intn(w), rng.Intn(h)
+		r := image.Rect(cx-t.Size/2, cy-t.Size/2, cx-t.Size/2+t.Size, cy-t.Size/2+t.Size)
+		draw.Draw(dst, r, image.NewUniform(fill), image.Point{}, draw.Src)
+	}
+	s.Picture = &myImg.Picture{ImgPath: s.Picture.ImgPath, Img: dst, Space: s.Picture.Space}
+	return nil
+}
+
+// Mosaic 把 4 个样本拼成一张 w x h 的图: 随机选取拼接中心, 每个样本缩放到覆盖自己的象限后裁剪
+// 各样本的标注随之缩放平移并裁剪到所在象限, minVisibility 含义同 Pipeline.MinVisibility
+// 结果沿用第一个样本的编码方式(Space), 其他编码的样本先转换过去再拼接
+func Mosaic(samples [4]Sample, w, h int, minVisibility float64, rng *rand.Rand) (Sample, error) {
+	if w <= 1 || h <= 1 {
+		return Sample{}, errors.New("mosaic size is too small")
+	}
+	cx := int(uniform(rng, 0.25, 0.75) * float64(w))
+	cy := int(uniform(rng, 0.25, 0.75) * float64(h))
+	quads := [4]image.Rectangle{
+		image.Rect(0, 0, cx, cy), image.Rect(cx, 0, w, cy),
+		image.Rect(0, cy, cx, h), image.Rect(cx, cy, w, h),
+	}
+	for _, in := range samples {
+		if in.Picture == nil || in.Picture.Img == nil {
+			return Sample{}, errors.New("image not loaded")
+		}
+	}
+	space := samples[0].Picture.Space
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	ann := &annotation.Annotations{}
+	for k, in := range samples {
+		q := quads[k]
+		iw, ih := in.Picture.GetSize()
+		scale := math.Max(float64(q.Dx())/float64(iw), float64(q.Dy())/float64(ih))
+		// 图片靠向拼接中心的角与中心对齐
+		tx, ty := float64(q.Min.X), float64(q.Min.Y)
+		if q.Min.X == 0 {
+			tx = float64(cx) - float64(iw)*scale
+		}
+		if q.Min.Y == 0 {
+			ty = float64(cy) - float64(ih)*scale
+		}
+		s := Sample{in.Picture, in.Ann.Clone()}
+		if err := warp(&s, myImg.Homography{scale, 0, tx, 0, scale, ty, 0, 0, 1}, w, h); err != nil {
+			return Sample{}, err
+		}
+		if s.Picture.Space != space {
+			conv := &myImg.Picture{}
+			if err := s.Picture.ConvertSpace(conv, space); err != nil {
+				return Sample{}, err
+			}
+			s.Picture = conv
+		}
+		draw.Draw(canvas, q, s.Picture.Img, q.Min, draw.Src)
+		if s.Ann != nil {
+			s.Ann.Clip(q, minVisibility)
+			ann.Boxes = append(ann.Boxes, s.Ann.Boxes...)
+			ann.Polygons = append(ann.Polygons, s.Ann.Polygons...)
+			ann.Keypoints = append(ann.Keypoints, s.Ann.Keypoints...)
+		}
+	}
+	return Sample{&myImg.Picture{Img: canvas, Space: space}, ann}, nil
+}
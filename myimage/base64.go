@@ -0,0 +1,248 @@
+package myimage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+/*
+base64 与 data URI(data:image/png;base64,...): 编码和解码都以流的方式进行, 不限制图片大小
+*/
+
+// WriteBase64 图片按指定格式编码, 以 base64 写入 w
+func (p *Picture) WriteBase64(w io.Writer, format ImageFormat) error {
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if err := p.Encode(enc, format); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ImgToBase64 图片按指定格式编码后转成 base64
+func (p *Picture) ImgToBase64(format ImageFormat) (string, error) {
+	var sb strings.Builder
+	if err := p.WriteBase64(&sb, format); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// WriteDataURI 图片按指定格式编码, 以 data URI 写入 w
+func (p *Picture) WriteDataURI(w io.Writer, format ImageFormat) error {
+	if _, err := io.WriteString(w, "data:"+format.MIME()+";base64,"); err != nil {
+		return err
+	}
+	return p.WriteBase64(w, format)
+}
+
+// ToDataURI 图片按指定格式编码后转成 data URI
+func (p *Picture) ToDataURI(format ImageFormat) (string, error) {
+	var sb strings.Builder
+	if err := p.WriteDataURI(&sb, format); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// FileToBase64 图片文件的内容直接转成 base64(不重新编码)
+func FileToBase64(imgPath string) (string, error) {
+	return fileToBase64(imgPath, false)
+}
+
+// FileToDataURI 图片文件的内容直接转成 data URI, MIME 类型根据文件内容识别
+func FileToDataURI(imgPath string) (string, error) {
+	return fileToBase64(imgPath, true)
+}
+
+// fileToBase64 按文件大小预先分配空间, 边读边编码
+func fileToBase64(imgPath string, dataURI bool) (string, error) {
+	f, err := os.Open(imgPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	br := bufio.NewReader(f)
+	var sb strings.Builder
+	sb.Grow(base64.StdEncoding.EncodedLen(int(info.Size())))
+	if dataURI {
+		head, _ := br.Peek(512)
+		sb.WriteString("data:" + DetectMIME(head) + ";base64,")
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, &sb)
+	if _, err = io.Copy(enc, br); err != nil {
+		return "", err
+	}
+	if err = enc.Close(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Base642File base64(或 data URI)解码后保存成文件
+func Base642File(datasource, imgPath string) (err error) {
+	src, _, err := openBase64(strings.NewReader(datasource))
+	if err != nil {
+		return
+	}
+	f, err := os.Create(imgPath)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(f, src); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// Base642buffer base64(或 data URI)解码到 buffer
+func Base642buffer(datasource string) (*bytes.Buffer, error) {
+	src, _, err := openBase64(strings.NewReader(datasource))
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, base64.StdEncoding.DecodedLen(len(datasource))))
+	if _, err = buf.ReadFrom(src); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// BufferToImg 解码图片数据(jpeg / png / gif)
+func BufferToImg(r io.Reader) (image.Image, error) {
+	m, _, err := image.Decode(r)
+	return m, err
+}
+
+// ReadBase64 从 r 中流式读取 base64(或 data URI)并解码成图片
+func ReadBase64(r io.Reader) (*Picture, error) {
+	src, _, err := openBase64(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := BufferToImg(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Picture{Img: img}, nil
+}
+
+// ParseDataURI 解析 data URI, 返回 MIME 类型和数据; 未声明类型时根据内容识别图片格式
+func ParseDataURI(uri string) (mime string, data []byte, err error) {
+	if len(uri) < 5 || !strings.EqualFold(uri[:5], "data:") {
+		return "", nil, errors.New("not a data URI")
+	}
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return "", nil, errors.New("invalid data URI")
+	}
+	mime, isBase64 := parseDataURIMeta(uri[5:comma])
+	payload := uri[comma+1:]
+	if isBase64 {
+		// 去掉换行和空格, 并兼容省略了末尾 '=' 的写法
+		payload = strings.Join(strings.Fields(payload), "")
+		payload = strings.TrimRight(payload, "=")
+		data, err = base64.RawStdEncoding.DecodeString(payload)
+	} else {
+		var s string
+		s, err = url.PathUnescape(payload)
+		data = []byte(s)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if mime == "" || mime == "application/octet-stream" {
+		// 按 RFC 2397, 未声明类型时默认为文本
+		if mime = DetectMIME(data); mime == "application/octet-stream" {
+			mime = "text/plain"
+		}
+	}
+	return mime, data, nil
+}
+
+// DataURIToPicture data URI 解码成图片
+func DataURIToPicture(uri string) (*Picture, error) {
+	mime, data, err := ParseDataURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return nil, errors.New("data URI is not an image: " + mime)
+	}
+	img, err := BufferToImg(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Picture{Img: img}, nil
+}
+
+// parseDataURIMeta 解析 "data:" 与 ',' 之间的部分, 如 "image/png;base64"
+func parseDataURIMeta(meta string) (mime string, isBase64 bool) {
+	parts := strings.Split(meta, ";")
+	if strings.Contains(parts[0], "/") {
+		mime = strings.ToLower(strings.TrimSpace(parts[0]))
+	}
+	for _, p := range parts[1:] {
+		if strings.EqualFold(strings.TrimSpace(p), "base64") {
+			isBase64 = true
+		}
+	}
+	return
+}
+
+// openBase64 返回解码后的数据流, r 可以是纯 base64 或 base64 编码的 data URI; mime 为 data URI 声明的类型
+func openBase64(r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(5)
+	if !strings.EqualFold(string(head), "data:") {
+		return base64.NewDecoder(base64.StdEncoding, br), "", nil
+	}
+	meta, err := br.ReadString(',')
+	if err != nil {
+		return nil, "", errors.New("invalid data URI")
+	}
+	mime, isBase64 := parseDataURIMeta(meta[5 : len(meta)-1])
+	if !isBase64 {
+		return nil, "", errors.New("data URI is not base64 encoded")
+	}
+	return base64.NewDecoder(base64.StdEncoding, br), mime, nil
+}
+
+// mimeSignatures 常见图片格式的文件头
+var mimeSignatures = []struct {
+	prefix string
+	mime   string
+}{
+	{"\xff\xd8\xff", "image/jpeg"},
+	{"\x89PNG\r\n\x1a\n", "image/png"},
+	{"GIF87a", "image/gif"},
+	{"GIF89a", "image/gif"},
+	{"BM", "image/bmp"},
+	{"II*\x00", "image/tiff"},
+	{"MM\x00*", "image/tiff"},
+	{"\x00\x00\x01\x00", "image/x-icon"},
+}
+
+// DetectMIME 根据数据开头的文件头识别图片的 MIME 类型, 无法识别时为 "application/octet-stream"
+func DetectMIME(data []byte) string {
+	for _, sig := range mimeSignatures {
+		if bytes.HasPrefix(data, []byte(sig.prefix)) {
+			return sig.mime
+		}
+	}
+	if len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,16 @@
+package myimage
+
+/*
+模糊
+*/
+
+// GaussianBlur 高斯模糊, sigma 为标准差(像素), 核半径取 3σ
+func (p *Picture) GaussianBlur(p1 *Picture, sigma float64) (err error) {
+	if sigma <= 0 {
+		return p.Copy(p1)
+	}
+	k := gaussianKernel(sigma, 0)
+	return p.applyPlanes(p1, false, func(data []float64, w, h int) []float64 {
+		return convolveSeparable(data, w, h, k)
+	})
+}
@@ -0,0 +1,47 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+颜色调整
+*/
+
+// AdjustColor 调整亮度、对比度、饱和度和色相
+// brightness、contrast、saturation 为系数(1 表示不变), hue 为色相旋转的角度(度); 灰度图只调整亮度和对比度
+func (p *Picture) AdjustColor(p1 *Picture, brightness, contrast, saturation, hue float64) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	var y, cb, cr []float64
+	if len(planes) == 3 {
+		y, cb, cr = toYCbCr(planes[0], planes[1], planes[2])
+	} else {
+		y = planes[0]
+	}
+
+	// 对比度以平均亮度为中心
+	m := mean(y)
+	cosH, sinH := math.Cos(hue*math.Pi/180), math.Sin(hue*math.Pi/180)
+	for i := range y {
+		y[i] = ((y[i]*brightness)-m*brightness)*contrast + m*brightness
+		if cb != nil {
+			u := cb[i] * brightness * contrast * saturation
+			v := cr[i] * brightness * contrast * saturation
+			cb[i] = u*cosH - v*sinH
+			cr[i] = u*sinH + v*cosH
+		}
+	}
+
+	if cb != nil {
+		r, g, b := fromYCbCr(y, cb, cr)
+		planes = [][]float64{r, g, b}
+	} else {
+		planes = [][]float64{y}
+	}
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
+	return
+}
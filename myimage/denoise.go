@@ -0,0 +1,183 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+保边去噪: 双边滤波和非局部均值
+*/
+
+// BilateralFilter 双边滤波
+// sigmaSpace 为空间高斯的标准差(像素), sigmaRange 为颜色差高斯的标准差(0~255)
+func (p *Picture) BilateralFilter(p1 *Picture, sigmaSpace, sigmaRange float64) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if sigmaSpace <= 0 || sigmaRange <= 0 {
+		return errors.New("sigma must be positive")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	radius := int(math.Ceil(2 * sigmaSpace))
+	nc := float64(len(planes))
+
+	// 空间权重
+	size := 2*radius + 1
+	spatial := make([]float64, size*size)
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			spatial[(dy+radius)*size+dx+radius] = math.Exp(-float64(dx*dx+dy*dy) / (2 * sigmaSpace * sigmaSpace))
+		}
+	}
+	// 颜色权重查找表, 下标为各通道平均的平方差(取整); 浮点图的值可超出 0~255, 超出查找表时直接计算
+	rangeLUT := make([]float64, 255*255+1)
+	for i := range rangeLUT {
+		rangeLUT[i] = math.Exp(-float64(i) / (2 * sigmaRange * sigmaRange))
+	}
+
+	out := make([][]float64, len(planes))
+	for c := range out {
+		out[c] = make([]float64, w*h)
+	}
+	parallelRows(h, func(y0, y1 int) {
+		acc := make([]float64, len(planes))
+		for i := y0; i < y1; i++ {
+			for j := 0; j < w; j++ {
+				center := i*w + j
+				var wsum float64
+				for c := range acc {
+					acc[c] = 0
+				}
+				for dy := -radius; dy <= radius; dy++ {
+					y := i + dy
+					if y < 0 || y >= h {
+						continue
+					}
+					for dx := -radius; dx <= radius; dx++ {
+						x := j + dx
+						if x < 0 || x >= w {
+							continue
+						}
+						idx := y*w + x
+						var d2 float64
+						for _, pl := range planes {
+							d := pl[idx] - pl[center]
+							d2 += d * d
+						}
+						var rw float64
+						if k := int(d2 / nc); k < len(rangeLUT) {
+							rw = rangeLUT[k]
+						} else {
+							rw = math.Exp(-d2 / nc / (2 * sigmaRange * sigmaRange))
+						}
+						wt := spatial[(dy+radius)*size+dx+radius] * rw
+						wsum += wt
+						for c, pl := range planes {
+							acc[c] += wt * pl[idx]
+						}
+					}
+				}
+				for c := range acc {
+					out[c][center] = acc[c] / wsum
+				}
+			}
+		}
+	})
+
+	p.setResult(p1, planesToImage(out, alpha, w, h, imageDepth(p.Img)))
+	return
+}
+
+// padPlane 以边界复制的方式向四周扩展 pad 个像素
+func padPlane(data []float64, w, h, pad int) []float64 {
+	pw, ph := w+2*pad, h+2*pad
+	out := make([]float64, pw*ph)
+	for i := 0; i < ph; i++ {
+		y := i - pad
+		if y < 0 {
+			y = 0
+		} else if y >= h {
+			y = h - 1
+		}
+		for j := 0; j < pw; j++ {
+			x := j - pad
+			if x < 0 {
+				x = 0
+			} else if x >= w {
+				x = w - 1
+			}
+			out[i*pw+j] = data[y*w+x]
+		}
+	}
+	return out
+}
+
+// NonLocalMeans 非局部均值去噪
+// patchSize 为比较块的边长(奇数, 如 7), searchSize 为搜索窗口边长(奇数, 如 21), strength 为滤波强度(0~255, 如 10)
+func (p *Picture) NonLocalMeans(p1 *Picture, patchSize, searchSize int, strength float64) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if patchSize < 1 || searchSize < 1 || patchSize%2 == 0 || searchSize%2 == 0 {
+		return errors.New("patch and search sizes must be positive odd numbers")
+	}
+	if strength <= 0 {
+		return errors.New("strength must be positive")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	pr, sr := patchSize/2, searchSize/2
+	pad := pr + sr
+	pw, ph := w+2*pad, h+2*pad
+	padded := make([][]float64, len(planes))
+	for c, pl := range planes {
+		padded[c] = padPlane(pl, w, h, pad)
+	}
+
+	// 对每个位移计算整幅图的块距离(积分图求块内平方差之和), 代价与块大小无关
+	norm := 1 / (float64(patchSize*patchSize*len(planes)) * strength * strength)
+	wsum := make([]float64, w*h)
+	acc := make([][]float64, len(planes))
+	for c := range acc {
+		acc[c] = make([]float64, w*h)
+	}
+	diff := make([]float64, pw*ph)
+	for dy := -sr; dy <= sr; dy++ {
+		for dx := -sr; dx <= sr; dx++ {
+			parallelRows(ph-2*sr, func(y0, y1 int) {
+				for i := y0 + sr; i < y1+sr; i++ {
+					for j := sr; j < pw-sr; j++ {
+						var d2 float64
+						for _, pl := range padded {
+							d := pl[i*pw+j] - pl[(i+dy)*pw+j+dx]
+							d2 += d * d
+						}
+						diff[i*pw+j] = d2
+					}
+				}
+			})
+			sum := integralOf(diff, pw, ph, false)
+			parallelRows(h, func(y0, y1 int) {
+				for i := y0; i < y1; i++ {
+					for j := 0; j < w; j++ {
+						y, x := i+pad, j+pad
+						d2 := rectSumOf(sum, pw, x-pr, y-pr, patchSize, patchSize)
+						wt := math.Exp(-math.Max(d2, 0) * norm)
+						wsum[i*w+j] += wt
+						for c, pl := range padded {
+							acc[c][i*w+j] += wt * pl[(y+dy)*pw+x+dx]
+						}
+					}
+				}
+			})
+		}
+	}
+	for c := range acc {
+		for i := range acc[c] {
+			acc[c][i] /= wsum[i]
+		}
+	}
+
+	p.setResult(p1, planesToImage(acc, alpha, w, h, imageDepth(p.Img)))
+	return
+}
@@ -0,0 +1,33 @@
+package myimage
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// 浮点图的值超出 1.0 时颜色差会超出查找表的范围
+func TestBilateralFilterFloatOverRange(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 8, 8), 1)
+	for i := range img.Pix {
+		img.Pix[i] = 3
+		if i%8 < 4 {
+			img.Pix[i] = 0
+		}
+	}
+	p1 := &Picture{}
+	if err := (&Picture{Img: img}).BilateralFilter(p1, 1.5, 30); err != nil {
+		t.Fatal(err)
+	}
+	out, ok := p1.Img.(*FloatImage)
+	if !ok {
+		t.Fatalf("got %T, want *FloatImage", p1.Img)
+	}
+	// 边缘两侧相差很大, 保边后仍接近原值
+	if v := out.Pix[7]; math.Abs(float64(v)-3) > 1e-3 {
+		t.Errorf("pixel (7, 0) = %v, want 3", v)
+	}
+	if v := out.Pix[0]; math.Abs(float64(v)) > 1e-3 {
+		t.Errorf("pixel (0, 0) = %v, want 0", v)
+	}
+}
@@ -0,0 +1,158 @@
+package myimage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+/*
+差异图: 在淡化的原图上高亮变化的像素, 用于视觉回归测试
+*/
+
+// DiffOptions 差异图选项
+type DiffOptions struct {
+	Tolerance    [4]uint8   // R/G/B/A 每个通道允许的最大差值, 任一通道超过即视为不同
+	AntiAliasing bool       // 检测抗锯齿像素, 抗锯齿造成的差异不计入变化
+	AATolerance  float64    // 抗锯齿检测时亮度差不超过该值的邻居视为相同
+	Alpha        float64    // 原图淡化后的不透明度(0~1), 零值时为 0.1
+	DiffColor    color.RGBA // 变化像素的颜色, 零值时为红色
+	AAColor      color.RGBA // 抗锯齿像素的颜色, 零值时为黄色
+}
+
+// DiffStats 差异统计
+type DiffStats struct {
+	Changed     int // 变化的像素数
+	AntiAliased int // 被判定为抗锯齿而忽略的像素数
+}
+
+// lumaAt 亮度, 0~255
+func lumaAt(img image.Image, x, y int) float64 {
+	c := NewU8color(img, x, y)
+	return 0.299*float64(c.Red) + 0.587*float64(c.Green) + 0.114*float64(c.Blue)
+}
+
+// hasManySiblings 周围至少有 3 个亮度相同的邻居(说明处于平坦区域而不是边缘)
+func hasManySiblings(img image.Image, x, y int, tol float64) bool {
+	b := img.Bounds()
+	v := lumaAt(img, x, y)
+	n := 0
+	if x == b.Min.X || x == b.Max.X-1 || y == b.Min.Y || y == b.Max.Y-1 {
+		n++
+	}
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			nx, ny := x+dx, y+dy
+			if !(image.Point{nx, ny}).In(b) {
+				continue
+			}
+			if d := lumaAt(img, nx, ny) - v; d <= tol && d >= -tol {
+				n++
+				if n > 2 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// antialiased 判断 img 中 (x, y) 是否为抗锯齿像素(参考 pixelmatch):
+// 邻居中同时有更亮和更暗的像素, 且最亮或最暗的邻居在两张图中都处于平坦区域
+func antialiased(img, other image.Image, x, y int, tol float64) bool {
+	b := img.Bounds()
+	v := lumaAt(img, x, y)
+	zeroes := 0
+	if x == b.Min.X || x == b.Max.X-1 || y == b.Min.Y || y == b.Max.Y-1 {
+		zeroes++
+	}
+	var minD, maxD float64
+	var minX, minY, maxX, maxY int
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			nx, ny := x+dx, y+dy
+			if !(image.Point{nx, ny}).In(b) {
+				continue
+			}
+			d := lumaAt(img, nx, ny) - v
+			if d <= tol && d >= -tol {
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+			} else if d < minD {
+				minD, minX, minY = d, nx, ny
+			} else if d > maxD {
+				maxD, maxX, maxY = d, nx, ny
+			}
+		}
+	}
+	if minD == 0 || maxD == 0 {
+		return false
+	}
+	ob := other.Bounds().Min.Sub(b.Min)
+	return (hasManySiblings(img, minX, minY, tol) && hasManySiblings(other, minX+ob.X, minY+ob.Y, tol)) ||
+		(hasManySiblings(img, maxX, maxY, tol) && hasManySiblings(other, maxX+ob.X, maxY+ob.Y, tol))
+}
+
+// Diff 与 other 比较, 差异图写入 p1: 淡化的原图上用 DiffColor 标出变化的像素
+func (p *Picture) Diff(p1 *Picture, other *Picture, opts DiffOptions) (stats DiffStats, err error) {
+	if p.Img == nil || other == nil || other.Img == nil {
+		err = errors.New("image not loaded")
+		return
+	}
+	if p.Img.Bounds().Size() != other.Img.Bounds().Size() {
+		err = errors.New("image sizes differ")
+		return
+	}
+	if opts.Alpha == 0 {
+		opts.Alpha = 0.1
+	}
+	if opts.DiffColor == (color.RGBA{}) {
+		opts.DiffColor = color.RGBA{255, 0, 0, 255}
+	}
+	if opts.AAColor == (color.RGBA{}) {
+		opts.AAColor = color.RGBA{255, 255, 0, 255}
+	}
+
+	img, oimg := p.Img, other.Img
+	b, ob := img.Bounds(), oimg.Bounds()
+	w, h := b.Dx(), b.Dy()
+	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
+	exceeds := func(a, b, tol uint8) bool {
+		if a > b {
+			return a-b > tol
+		}
+		return b-a > tol
+	}
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			x, y := b.Min.X+j, b.Min.Y+i
+			c1 := NewU8color(img, x, y)
+			c2 := NewU8color(oimg, ob.Min.X+j, ob.Min.Y+i)
+			changed := exceeds(c1.Red, c2.Red, opts.Tolerance[0]) || exceeds(c1.Green, c2.Green, opts.Tolerance[1]) ||
+				exceeds(c1.Blue, c2.Blue, opts.Tolerance[2]) || exceeds(c1.Alpha, c2.Alpha, opts.Tolerance[3])
+			if changed {
+				if opts.AntiAliasing && (antialiased(img, oimg, x, y, opts.AATolerance) || antialiased(oimg, img, ob.Min.X+j, ob.Min.Y+i, opts.AATolerance)) {
+					stats.AntiAliased++
+					newImg.SetRGBA(j, i, opts.AAColor)
+				} else {
+					stats.Changed++
+					newImg.SetRGBA(j, i, opts.DiffColor)
+				}
+				continue
+			}
+			// 未变化: 灰度化后与白色混合
+			v := uint8(255 + (lumaAt(img, x, y)-255)*opts.Alpha*float64(c1.Alpha)/255)
+			newImg.SetRGBA(j, i, color.RGBA{v, v, v, 255})
+		}
+	}
+	p1.Img = newImg
+	return
+}
@@ -0,0 +1,324 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+二值图的距离变换、细化和中轴: 亮度 >= 128 的像素为前景
+距离变换给出每个前景像素到最近背景像素的距离, 可用于测量线条宽度; 细化和中轴把形状变成单像素宽的骨架
+*/
+
+// DistanceMetric 距离变换的度量
+type DistanceMetric uint8
+
+const (
+	DistanceEuclidean   DistanceMetric = iota // 精确欧氏距离(Felzenszwalb–Huttenlocher)
+	DistanceChamfer34                         // 3-4 倒角距离, 3x3 邻域近似, 最快
+	DistanceChamfer5711                       // 5-7-11 倒角距离, 5x5 邻域近似, 误差更小
+)
+
+// ParseDistanceMetric 按名称解析: euclidean、chamfer34、chamfer5711
+func ParseDistanceMetric(name string) (DistanceMetric, error) {
+	switch strings.ToLower(name) {
+	case "euclidean":
+		return DistanceEuclidean, nil
+	case "chamfer34":
+		return DistanceChamfer34, nil
+	case "chamfer5711":
+		return DistanceChamfer5711, nil
+	}
+	return DistanceEuclidean, fmt.Errorf("unknown distance metric %q", name)
+}
+
+// binaryOf 二值化, 亮度 >= 128 为前景
+func binaryOf(img image.Image) (fg []bool, w, h int) {
+	luma, w, h := lumaPlane(img)
+	fg = make([]bool, w*h)
+	for i, v := range luma {
+		fg[i] = v >= 128
+	}
+	return
+}
+
+// edt1d 一维平方距离变换(下包络抛物线), f 为输入, 结果写回 f
+func edt1d(f []float64, v []int, z []float64, d []float64) {
+	n := len(f)
+	k := 0
+	v[0] = 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < n; q++ {
+		if math.IsInf(f[q], 1) {
+			continue
+		}
+		if math.IsInf(f[v[k]], 1) {
+			// 之前只有无穷大, 直接替换
+			v[k] = q
+			continue
+		}
+		var s float64
+		for {
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+			if s > z[k] || k == 0 {
+				break
+			}
+			k--
+		}
+		if s <= z[k] {
+			v[k] = q
+			z[k+1] = math.Inf(1)
+			continue
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = math.Inf(1)
+	}
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		dq := float64(q - v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+	copy(f, d)
+}
+
+// euclideanDistance 精确欧氏距离变换: 先按列、再按行做一维平方距离变换
+func euclideanDistance(fg []bool, w, h int) []float64 {
+	dist := make([]float64, w*h)
+	for i, f := range fg {
+		if f {
+			dist[i] = math.Inf(1)
+		}
+	}
+	n := w
+	if h > n {
+		n = h
+	}
+	f := make([]float64, n)
+	d := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f[y] = dist[y*w+x]
+		}
+		edt1d(f[:h], v, z, d)
+		for y := 0; y < h; y++ {
+			dist[y*w+x] = f[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		edt1d(dist[y*w:(y+1)*w], v, z, d)
+	}
+	for i, v := range dist {
+		dist[i] = math.Sqrt(v)
+	}
+	return dist
+}
+
+// chamferDistance 两遍扫描的倒角距离变换, mask 为前向扫描使用的 (dx, dy, 权重), 结果除以 unit
+func chamferDistance(fg []bool, w, h int, mask [][3]int, unit float64) []float64 {
+	dist := make([]float64, w*h)
+	for i, f := range fg {
+		if f {
+			dist[i] = math.Inf(1)
+		}
+	}
+	pass := func(y, x, sign int) {
+		k := y*w + x
+		if dist[k] == 0 {
+			return
+		}
+		for _, m := range mask {
+			nx, ny := x+sign*m[0], y+sign*m[1]
+			if nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			if d := dist[ny*w+nx] + float64(m[2]); d < dist[k] {
+				dist[k] = d
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pass(y, x, 1)
+		}
+	}
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			pass(y, x, -1)
+		}
+	}
+	for i := range dist {
+		dist[i] /= unit
+	}
+	return dist
+}
+
+// 倒角距离前向扫描的邻域(已处理过的一侧), 反向扫描时取相反方向
+var (
+	chamfer34Mask   = [][3]int{{-1, 0, 3}, {-1, -1, 4}, {0, -1, 3}, {1, -1, 4}}
+	chamfer5711Mask = [][3]int{
+		{-1, 0, 5}, {-1, -1, 7}, {0, -1, 5}, {1, -1, 7},
+		{-1, -2, 11}, {1, -2, 11}, {-2, -1, 11}, {2, -1, 11},
+	}
+)
+
+// DistanceTransform 距离变换: 每个前景像素到最近背景像素的距离(像素), 背景为 0
+// 没有背景像素时距离为 +Inf; p1 中写入归一化到 0~255 的灰度图便于查看, 可以为 nil
+func (p *Picture) DistanceTransform(p1 *Picture, metric DistanceMetric) (*Plane, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	fg, w, h := binaryOf(p.Img)
+	var dist []float64
+	switch metric {
+	case DistanceEuclidean:
+		dist = euclideanDistance(fg, w, h)
+	case DistanceChamfer34:
+		dist = chamferDistance(fg, w, h, chamfer34Mask, 3)
+	case DistanceChamfer5711:
+		dist = chamferDistance(fg, w, h, chamfer5711Mask, 5)
+	default:
+		return nil, fmt.Errorf("unknown distance metric %d", metric)
+	}
+	pl := &Plane{w, h, dist}
+	if p1 != nil {
+		max := 0.0
+		for _, v := range dist {
+			if !math.IsInf(v, 1) && v > max {
+				max = v
+			}
+		}
+		if err := pl.ToPicture(p1, 0, math.Max(max, 1)); err != nil {
+			return nil, err
+		}
+	}
+	return pl, nil
+}
+
+// neighbors8 3x3 邻域按 P2..P9(从正上方开始顺时针)的顺序
+func neighbors8(fg []bool, w, h, x, y int) (n [8]bool) {
+	d := [8][2]int{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+	for k, o := range d {
+		nx, ny := x+o[0], y+o[1]
+		n[k] = nx >= 0 && ny >= 0 && nx < w && ny < h && fg[ny*w+nx]
+	}
+	return
+}
+
+// transitions 按顺时针方向 0 -> 1 的变化次数 A(P), 以及前景邻居数 B(P)
+func transitions(n [8]bool) (a, b int) {
+	for k := 0; k < 8; k++ {
+		if n[k] {
+			b++
+		}
+		if !n[k] && n[(k+1)%8] {
+			a++
+		}
+	}
+	return
+}
+
+// zhangSuen Zhang–Suen 细化, 原地修改 fg
+func zhangSuen(fg []bool, w, h int) {
+	for changed := true; changed; {
+		changed = false
+		for step := 0; step < 2; step++ {
+			var del []int
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					if !fg[y*w+x] {
+						continue
+					}
+					n := neighbors8(fg, w, h, x, y)
+					a, b := transitions(n)
+					if b < 2 || b > 6 || a != 1 {
+						continue
+					}
+					// n[0]=P2 上, n[2]=P4 右, n[4]=P6 下, n[6]=P8 左
+					if step == 0 && (n[0] && n[2] && n[4] || n[2] && n[4] && n[6]) {
+						continue
+					}
+					if step == 1 && (n[0] && n[2] && n[6] || n[0] && n[4] && n[6]) {
+						continue
+					}
+					del = append(del, y*w+x)
+				}
+			}
+			for _, k := range del {
+				fg[k] = false
+			}
+			changed = changed || len(del) > 0
+		}
+	}
+}
+
+// skeletonToImage 前景为 255 的灰度图
+func skeletonToImage(fg []bool, w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i, f := range fg {
+		if f {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+// Thin Zhang–Suen 细化, 得到单像素宽的骨架(白色)
+func (p *Picture) Thin(p1 *Picture) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	fg, w, h := binaryOf(p.Img)
+	zhangSuen(fg, w, h)
+	p1.Img = skeletonToImage(fg, w, h)
+	return
+}
+
+// MedialAxis 中轴: 按欧氏距离从小到大依次删除不影响连通性的非端点像素, 留下位于形状中间的骨架
+// p1 为骨架图(白色), 返回的平面在骨架上为该点到边界的距离(局部宽度的一半), 其余为 0
+func (p *Picture) MedialAxis(p1 *Picture) (*Plane, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	fg, w, h := binaryOf(p.Img)
+	dist := euclideanDistance(fg, w, h)
+	var order []int
+	for i, f := range fg {
+		if f {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return dist[order[i]] < dist[order[j]] })
+	for changed := true; changed; {
+		changed = false
+		for _, k := range order {
+			if !fg[k] {
+				continue
+			}
+			a, b := transitions(neighbors8(fg, w, h, k%w, k/w))
+			// 只有一个邻居的端点保留, 以免骨架的分支被逐渐缩短
+			if a == 1 && b >= 2 && b <= 6 {
+				fg[k] = false
+				changed = true
+			}
+		}
+	}
+	axis := &Plane{w, h, make([]float64, w*h)}
+	for i, f := range fg {
+		if f {
+			axis.Data[i] = dist[i]
+		}
+	}
+	p1.Img = skeletonToImage(fg, w, h)
+	return axis, nil
+}
@@ -0,0 +1,247 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+/*
+抖动: 把图片映射到任意调色板(包括 1 位黑白), 用误差扩散或有序抖动代替直接取最近颜色, 避免色带
+结果为 *image.Paletted, Save 到 .gif / .png 时直接使用该调色板(PNG 为 8 位索引色)
+*/
+
+// PaletteBW 1 位黑白调色板, 用于电子墨水屏等
+var PaletteBW = color.Palette{color.Black, color.White}
+
+// DitherMethod 抖动方法
+type DitherMethod uint8
+
+const (
+	DitherNone           DitherMethod = iota // 不抖动, 直接取最近颜色
+	DitherFloydSteinberg                     // Floyd–Steinberg 误差扩散
+	DitherAtkinson                           // Atkinson, 只扩散 3/4 的误差, 对比度高
+	DitherJarvis                             // Jarvis–Judice–Ninke, 扩散范围大, 更平滑
+	DitherSierra                             // Sierra(三行)
+	DitherBayer                              // Bayer 有序抖动, 规则网格图案, 无误差传播
+)
+
+// ParseDitherMethod 按名称解析: none、floyd-steinberg、atkinson、jarvis、sierra、bayer
+func ParseDitherMethod(name string) (DitherMethod, error) {
+	switch strings.ToLower(name) {
+	case "none":
+		return DitherNone, nil
+	case "floyd-steinberg", "floydsteinberg", "fs":
+		return DitherFloydSteinberg, nil
+	case "atkinson":
+		return DitherAtkinson, nil
+	case "jarvis", "jjn":
+		return DitherJarvis, nil
+	case "sierra":
+		return DitherSierra, nil
+	case "bayer":
+		return DitherBayer, nil
+	}
+	return DitherNone, fmt.Errorf("unknown dither method %q", name)
+}
+
+// DitherOptions 抖动选项
+type DitherOptions struct {
+	Method     DitherMethod
+	Serpentine bool    // 误差扩散时奇数行从右往左扫描, 减少方向性纹理
+	BayerSize  int     // Bayer 矩阵边长: 2、4 或 8, 0 时为 4
+	Spread     float64 // Bayer 抖动的幅度(像素值), 0 时按调色板颜色数估计
+}
+
+// diffusionWeight 误差扩散的一项: 相对当前像素的偏移和权重
+type diffusionWeight struct {
+	dx, dy int
+	w      float64
+}
+
+// diffusionKernels 各误差扩散方法的扩散系数
+var diffusionKernels = map[DitherMethod][]diffusionWeight{
+	DitherFloydSteinberg: {
+		{1, 0, 7.0 / 16},
+		{-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	DitherAtkinson: {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+	DitherJarvis: {
+		{1, 0, 7.0 / 48}, {2, 0, 5.0 / 48},
+		{-2, 1, 3.0 / 48}, {-1, 1, 5.0 / 48}, {0, 1, 7.0 / 48}, {1, 1, 5.0 / 48}, {2, 1, 3.0 / 48},
+		{-2, 2, 1.0 / 48}, {-1, 2, 3.0 / 48}, {0, 2, 5.0 / 48}, {1, 2, 3.0 / 48}, {2, 2, 1.0 / 48},
+	},
+	DitherSierra: {
+		{1, 0, 5.0 / 32}, {2, 0, 3.0 / 32},
+		{-2, 1, 2.0 / 32}, {-1, 1, 4.0 / 32}, {0, 1, 5.0 / 32}, {1, 1, 4.0 / 32}, {2, 1, 2.0 / 32},
+		{-1, 2, 2.0 / 32}, {0, 2, 3.0 / 32}, {1, 2, 2.0 / 32},
+	},
+}
+
+// bayerMatrix n x n 的 Bayer 阈值矩阵(n 为 2 的幂), 取值 0 ~ n²-1
+func bayerMatrix(n int) []int {
+	m := []int{0}
+	for size := 1; size < n; size *= 2 {
+		next := make([]int, 4*size*size)
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				v := 4 * m[i*size+j]
+				next[i*2*size+j] = v
+				next[i*2*size+j+size] = v + 2
+				next[(i+size)*2*size+j] = v + 3
+				next[(i+size)*2*size+j+size] = v + 1
+			}
+		}
+		m = next
+	}
+	return m
+}
+
+// paletteMatcher 带缓存的最近颜色查找
+type paletteMatcher struct {
+	palette     color.Palette
+	transparent int // 透明色下标, 没有时为 -1
+	cache       map[[3]uint8]uint8
+}
+
+func newPaletteMatcher(palette color.Palette) *paletteMatcher {
+	m := &paletteMatcher{palette: palette, transparent: -1, cache: make(map[[3]uint8]uint8)}
+	for i, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			m.transparent = i
+		}
+	}
+	return m
+}
+
+// match 最近颜色的下标及其 RGB
+func (m *paletteMatcher) match(r, g, b float64) (uint8, [3]float64) {
+	key := [3]uint8{Clip(float32(r+0.5), 0, 255), Clip(float32(g+0.5), 0, 255), Clip(float32(b+0.5), 0, 255)}
+	idx, ok := m.cache[key]
+	if !ok {
+		idx = uint8(nearestColor(m.palette, float64(key[0]), float64(key[1]), float64(key[2])))
+		m.cache[key] = idx
+	}
+	cr, cg, cb, _ := m.palette[idx].RGBA()
+	return idx, [3]float64{float64(cr >> 8), float64(cg >> 8), float64(cb >> 8)}
+}
+
+// Dither 按 opts 把图片抖动到调色板 palette(最多 256 色), 结果为 *image.Paletted
+// alpha < 128 的像素在调色板含透明色时映射为透明, 且不参与误差扩散; 调色板只有透明色时整张图都是透明的
+func (p *Picture) Dither(p1 *Picture, palette color.Palette, opts DitherOptions) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if len(palette) == 0 || len(palette) > 256 {
+		return errors.New("palette size must be between 1 and 256")
+	}
+	m := newPaletteMatcher(palette)
+	opaque := len(palette)
+	if m.transparent >= 0 {
+		opaque--
+	}
+
+	b := p.Img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// 调色板只有透明色(如全透明的帧)时, 所有像素都映射为透明
+	if opaque == 0 {
+		out := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+		for i := range out.Pix {
+			out.Pix[i] = uint8(m.transparent)
+		}
+		p.setResult(p1, out)
+		return
+	}
+	// 未预乘 alpha 的 RGB 工作缓冲区
+	buf := make([][3]float64, w*h)
+	skip := make([]bool, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			c := color.NRGBAModel.Convert(p.Img.At(b.Min.X+j, b.Min.Y+i)).(color.NRGBA)
+			buf[i*w+j] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+			skip[i*w+j] = c.A < 128 && m.transparent >= 0
+		}
+	}
+	out := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+
+	switch opts.Method {
+	case DitherNone, DitherBayer:
+		var bayer []int
+		n, spread := 1, 0.0
+		if opts.Method == DitherBayer {
+			n = opts.BayerSize
+			if n == 0 {
+				n = 4
+			}
+			if n != 2 && n != 4 && n != 8 {
+				return errors.New("bayer size must be 2, 4 or 8")
+			}
+			bayer = bayerMatrix(n)
+			spread = opts.Spread
+			if spread == 0 {
+				// 均匀调色板每个通道约 cbrt(颜色数) 级, 幅度取相邻两级的间隔
+				levels := math.Max(2, math.Round(math.Cbrt(float64(opaque))))
+				spread = 255 / (levels - 1)
+			}
+		}
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				k := i*w + j
+				if skip[k] {
+					out.Pix[i*out.Stride+j] = uint8(m.transparent)
+					continue
+				}
+				var t float64
+				if bayer != nil {
+					t = spread * ((float64(bayer[(i%n)*n+j%n])+0.5)/float64(n*n) - 0.5)
+				}
+				c := buf[k]
+				out.Pix[i*out.Stride+j], _ = m.match(c[0]+t, c[1]+t, c[2]+t)
+			}
+		}
+	default:
+		kernel, ok := diffusionKernels[opts.Method]
+		if !ok {
+			return fmt.Errorf("unknown dither method %d", opts.Method)
+		}
+		for i := 0; i < h; i++ {
+			dir, j0, j1 := 1, 0, w
+			if opts.Serpentine && i%2 == 1 {
+				dir, j0, j1 = -1, w-1, -1
+			}
+			for j := j0; j != j1; j += dir {
+				k := i*w + j
+				if skip[k] {
+					out.Pix[i*out.Stride+j] = uint8(m.transparent)
+					continue
+				}
+				// 限制累积误差, 避免大片饱和区域把误差传得过远
+				c := buf[k]
+				for ch := 0; ch < 3; ch++ {
+					c[ch] = math.Max(0, math.Min(255, c[ch]))
+				}
+				idx, q := m.match(c[0], c[1], c[2])
+				out.Pix[i*out.Stride+j] = idx
+				// 误差按系数扩散到尚未处理的邻居, 反向扫描时水平方向镜像
+				for _, kw := range kernel {
+					x, y := j+kw.dx*dir, i+kw.dy
+					if x < 0 || x >= w || y >= h || skip[y*w+x] {
+						continue
+					}
+					for ch := 0; ch < 3; ch++ {
+						buf[y*w+x][ch] += (c[ch] - q[ch]) * kw.w
+					}
+				}
+			}
+		}
+	}
+	p.setResult(p1, out)
+	return
+}
@@ -0,0 +1,134 @@
+package myimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+/*
+绘制: 矩形、直线、点阵文字, 直接画在 draw.Image 上
+*/
+
+// ToRGBA 把任意图片复制成以 (0, 0) 为原点的 *image.RGBA, 便于在上面绘制
+func ToRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// FillRect 填充矩形(按 alpha 混合)
+func FillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// DrawRect 画矩形边框, 线宽 thickness 向矩形内部延伸
+func DrawRect(img draw.Image, r image.Rectangle, c color.Color, thickness int) {
+	if thickness < 1 {
+		thickness = 1
+	}
+	t := thickness
+	FillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+t), c)
+	FillRect(img, image.Rect(r.Min.X, r.Max.Y-t, r.Max.X, r.Max.Y), c)
+	FillRect(img, image.Rect(r.Min.X, r.Min.Y+t, r.Min.X+t, r.Max.Y-t), c)
+	FillRect(img, image.Rect(r.Max.X-t, r.Min.Y+t, r.Max.X, r.Max.Y-t), c)
+}
+
+// DrawLine Bresenham 画线, thickness 为线宽
+func DrawLine(img draw.Image, x0, y0, x1, y1 int, c color.Color, thickness int) {
+	if thickness < 1 {
+		thickness = 1
+	}
+	half := thickness / 2
+	dx, dy := x1-x0, y1-y0
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx - dy
+	for {
+		FillRect(img, image.Rect(x0-half, y0-half, x0-half+thickness, y0-half+thickness), c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 > -dy {
+			e -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// TextSize 文字占用的宽和高, scale 为放大倍数; 多行文字以 '\n' 分隔
+func TextSize(text string, scale int) (w, h int) {
+	if scale < 1 {
+		scale = 1
+	}
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 0 {
+			if lw := (n*(glyphWidth+1) - 1) * scale; lw > w {
+				w = lw
+			}
+		}
+	}
+	h = (len(lines)*(glyphHeight+2) - 2) * scale
+	return
+}
+
+// DrawText 以 (x, y) 为左上角写文字, 返回文字占用的区域
+func DrawText(img draw.Image, x, y int, text string, c color.Color, scale int) image.Rectangle {
+	if scale < 1 {
+		scale = 1
+	}
+	src := image.NewUniform(c)
+	for li, line := range strings.Split(text, "\n") {
+		oy := y + li*(glyphHeight+2)*scale
+		for ci, r := range []rune(line) {
+			ox := x + ci*(glyphWidth+1)*scale
+			g := glyphFor(r)
+			for gy, row := range g {
+				for gx, ch := range row {
+					if ch == '#' {
+						px := image.Rect(ox+gx*scale, oy+gy*scale, ox+(gx+1)*scale, oy+(gy+1)*scale)
+						draw.Draw(img, px, src, image.Point{}, draw.Over)
+					}
+				}
+			}
+		}
+	}
+	w, h := TextSize(text, scale)
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// DrawLabel 在 (x, y) 处写带背景色的标签, 文字颜色根据背景亮度自动选择黑或白
+func DrawLabel(img draw.Image, x, y int, text string, bg color.Color, scale int) image.Rectangle {
+	if scale < 1 {
+		scale = 1
+	}
+	w, h := TextSize(text, scale)
+	r := image.Rect(x, y, x+w+2*scale, y+h+2*scale)
+	FillRect(img, r, bg)
+	cr, cg, cb, _ := bg.RGBA()
+	fg := color.Color(color.White)
+	if 0.299*float64(cr)+0.587*float64(cg)+0.114*float64(cb) > 0x7fff {
+		fg = color.Black
+	}
+	DrawText(img, x+scale, y+scale, text, fg, scale)
+	return r
+}
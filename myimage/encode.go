@@ -0,0 +1,98 @@
+package myimage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+/*
+图片编码: 输出格式的选择, 供 Save 和 base64 / data URI 使用
+*/
+
+// ImageFormat 图片编码格式
+type ImageFormat uint8
+
+const (
+	FormatJPEG ImageFormat = iota
+	FormatPNG
+	FormatGIF
+)
+
+// JPEGQuality 编码 jpeg 时使用的质量
+const JPEGQuality = 100
+
+// String 格式名
+func (f ImageFormat) String() string {
+	switch f {
+	case FormatPNG:
+		return "png"
+	case FormatGIF:
+		return "gif"
+	}
+	return "jpeg"
+}
+
+// MIME 格式对应的 MIME 类型
+func (f ImageFormat) MIME() string {
+	return "image/" + f.String()
+}
+
+// ParseImageFormat 按名称或 MIME 类型解析格式, 如 "png"、"jpg"、"image/jpeg"
+func ParseImageFormat(name string) (ImageFormat, error) {
+	switch strings.TrimPrefix(strings.ToLower(name), "image/") {
+	case "jpeg", "jpg":
+		return FormatJPEG, nil
+	case "png":
+		return FormatPNG, nil
+	case "gif":
+		return FormatGIF, nil
+	}
+	return FormatJPEG, fmt.Errorf("unsupported image format %q", name)
+}
+
+// FormatFromPath 按文件扩展名选择格式, 无法识别时为 jpeg
+func FormatFromPath(path string) ImageFormat {
+	f, err := ParseImageFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return FormatJPEG
+	}
+	return f
+}
+
+// Encode 按指定格式把图片编码写入 w
+func (p *Picture) Encode(w io.Writer, format ImageFormat) error {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if format == FormatPNG && p.Space != SpaceSRGB {
+		// 非 sRGB 编码时写入 gAMA 块, 重新加载后仍能识别
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, p.Img, format); err != nil {
+			return err
+		}
+		_, err := w.Write(withGAMA(buf.Bytes(), p.Space))
+		return err
+	}
+	return encodeImage(w, p.Img, format)
+}
+
+// encodeImage 按指定格式编码
+func encodeImage(w io.Writer, img image.Image, format ImageFormat) error {
+	switch format {
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatGIF:
+		return gif.Encode(w, img, nil)
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: JPEGQuality})
+	}
+	return fmt.Errorf("unsupported image format %d", format)
+}
@@ -0,0 +1,429 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+/*
+局部特征: Harris / Shi-Tomasi 角点, FAST 检测, ORB 风格的二进制描述子及匹配
+*/
+
+// PointF 浮点坐标点
+type PointF struct {
+	X, Y float64
+}
+
+// KeyPoint 特征点
+type KeyPoint struct {
+	X, Y     float64
+	Response float64 // 响应值, 越大越显著
+	Angle    float64 // 主方向(弧度), 未计算时为 0
+}
+
+// CornerMethod 角点响应的计算方式
+type CornerMethod uint8
+
+const (
+	CornerHarris    CornerMethod = iota // det(M) - k·tr(M)²
+	CornerShiTomasi                     // min(λ1, λ2)
+)
+
+// sobel 计算平面的 x/y 方向 Sobel 梯度, 边界像素为 0
+func sobel(data []float64, w, h int) (gx, gy []float64) {
+	gx = make([]float64, w*h)
+	gy = make([]float64, w*h)
+	for i := 1; i < h-1; i++ {
+		for j := 1; j < w-1; j++ {
+			p := func(dx, dy int) float64 { return data[(i+dy)*w+j+dx] }
+			gx[i*w+j] = (p(1, -1) + 2*p(1, 0) + p(1, 1)) - (p(-1, -1) + 2*p(-1, 0) + p(-1, 1))
+			gy[i*w+j] = (p(-1, 1) + 2*p(0, 1) + p(1, 1)) - (p(-1, -1) + 2*p(0, -1) + p(1, -1))
+		}
+	}
+	return
+}
+
+// gaussianBlur5 用 [1 4 6 4 1]/16 可分离核做平滑, 边界复制
+func gaussianBlur5(data []float64, w, h int) []float64 {
+	k := [5]float64{1.0 / 16, 4.0 / 16, 6.0 / 16, 4.0 / 16, 1.0 / 16}
+	clamp := func(v, n int) int {
+		if v < 0 {
+			return 0
+		} else if v >= n {
+			return n - 1
+		}
+		return v
+	}
+	tmp := make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			var s float64
+			for t := -2; t <= 2; t++ {
+				s += k[t+2] * data[i*w+clamp(j+t, w)]
+			}
+			tmp[i*w+j] = s
+		}
+	}
+	out := make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			var s float64
+			for t := -2; t <= 2; t++ {
+				s += k[t+2] * tmp[clamp(i+t, h)*w+j]
+			}
+			out[i*w+j] = s
+		}
+	}
+	return out
+}
+
+// cornerResponse 计算每个像素的角点响应, blockSize 为结构张量的窗口大小
+func cornerResponse(data []float64, w, h int, method CornerMethod, blockSize int, k float64) []float64 {
+	gx, gy := sobel(data, w, h)
+	xx := make([]float64, w*h)
+	yy := make([]float64, w*h)
+	xy := make([]float64, w*h)
+	for i := range gx {
+		xx[i] = gx[i] * gx[i]
+		yy[i] = gy[i] * gy[i]
+		xy[i] = gx[i] * gy[i]
+	}
+	sxx := integralOf(xx, w, h, false)
+	syy := integralOf(yy, w, h, false)
+	sxy := integralOf(xy, w, h, false)
+
+	resp := make([]float64, w*h)
+	pad := blockSize / 2
+	for i := pad; i < h-blockSize+pad+1; i++ {
+		for j := pad; j < w-blockSize+pad+1; j++ {
+			a := rectSumOf(sxx, w, j-pad, i-pad, blockSize, blockSize)
+			c := rectSumOf(syy, w, j-pad, i-pad, blockSize, blockSize)
+			b := rectSumOf(sxy, w, j-pad, i-pad, blockSize, blockSize)
+			if method == CornerShiTomasi {
+				resp[i*w+j] = (a+c)/2 - math.Sqrt((a-c)*(a-c)/4+b*b)
+			} else {
+				resp[i*w+j] = a*c - b*b - k*(a+c)*(a+c)
+			}
+		}
+	}
+	return resp
+}
+
+// selectPeaks 从响应图中挑选局部极大值, 并按最小距离做抑制, 最多 max 个 (max <= 0 表示不限)
+func selectPeaks(resp []float64, w, h int, threshold, minDist float64, max int) []KeyPoint {
+	var kps []KeyPoint
+	for i := 1; i < h-1; i++ {
+		for j := 1; j < w-1; j++ {
+			v := resp[i*w+j]
+			if v <= threshold {
+				continue
+			}
+			isMax := true
+			for dy := -1; dy <= 1 && isMax; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && resp[(i+dy)*w+j+dx] > v {
+						isMax = false
+						break
+					}
+				}
+			}
+			if isMax {
+				kps = append(kps, KeyPoint{X: float64(j), Y: float64(i), Response: v})
+			}
+		}
+	}
+	sort.SliceStable(kps, func(a, b int) bool { return kps[a].Response > kps[b].Response })
+
+	if minDist <= 1 {
+		if max > 0 && len(kps) > max {
+			kps = kps[:max]
+		}
+		return kps
+	}
+	out := kps[:0:0]
+	for _, kp := range kps {
+		if max > 0 && len(out) >= max {
+			break
+		}
+		ok := true
+		for _, o := range out {
+			if math.Hypot(o.X-kp.X, o.Y-kp.Y) < minDist {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, kp)
+		}
+	}
+	return out
+}
+
+// DetectCorners Harris / Shi-Tomasi 角点检测
+// quality: 相对最大响应的阈值比例(如 0.01); minDist: 角点之间的最小距离; maxCorners <= 0 表示不限数量
+func (p *Picture) DetectCorners(method CornerMethod, maxCorners int, quality, minDist float64) ([]KeyPoint, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	data, w, h := lumaPlane(p.Img)
+	resp := cornerResponse(data, w, h, method, 3, 0.04)
+	maxResp := 0.0
+	for _, v := range resp {
+		maxResp = math.Max(maxResp, v)
+	}
+	if maxResp == 0 {
+		return nil, nil
+	}
+	kps := selectPeaks(resp, w, h, maxResp*quality, minDist, maxCorners)
+	return offsetKeyPoints(p, kps), nil
+}
+
+// offsetKeyPoints 把平面坐标转换为原图坐标
+func offsetKeyPoints(p *Picture, kps []KeyPoint) []KeyPoint {
+	min := p.Img.Bounds().Min
+	for i := range kps {
+		kps[i].X += float64(min.X)
+		kps[i].Y += float64(min.Y)
+	}
+	return kps
+}
+
+// fastCircle 半径为 3 的 Bresenham 圆上的 16 个点
+var fastCircle = [16][2]int{
+	{0, -3}, {1, -3}, {2, -2}, {3, -1}, {3, 0}, {3, 1}, {2, 2}, {1, 3},
+	{0, 3}, {-1, 3}, {-2, 2}, {-3, 1}, {-3, 0}, {-3, -1}, {-2, -2}, {-1, -3},
+}
+
+// fastScore FAST-9 检测, 是角点时返回圆上超出阈值部分之和, 否则返回 0
+func fastScore(data []float64, w, x, y int, t float64) float64 {
+	c := data[y*w+x]
+	var d [16]float64
+	for k, o := range fastCircle {
+		d[k] = data[(y+o[1])*w+x+o[0]] - c
+	}
+	best := 0.0
+	for _, sign := range [2]float64{1, -1} {
+		// 环形, 遍历两圈以处理跨越起点的连续弧
+		run := 0
+		for k := 0; k < 32 && run < 9; k++ {
+			if sign*d[k%16] > t {
+				run++
+			} else {
+				run = 0
+			}
+		}
+		if run < 9 {
+			continue
+		}
+		s := 0.0
+		for _, v := range d {
+			if v*sign > t {
+				s += v*sign - t
+			}
+		}
+		best = math.Max(best, s)
+	}
+	return best
+}
+
+// FAST FAST-9 角点检测, threshold 为灰度差阈值(0~255), nonmax 为 true 时做 3x3 非极大值抑制
+func (p *Picture) FAST(threshold float64, nonmax bool) ([]KeyPoint, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	data, w, h := lumaPlane(p.Img)
+	kps := fastDetect(data, w, h, threshold, nonmax)
+	return offsetKeyPoints(p, kps), nil
+}
+
+func fastDetect(data []float64, w, h int, threshold float64, nonmax bool) []KeyPoint {
+	score := make([]float64, w*h)
+	for i := 3; i < h-3; i++ {
+		for j := 3; j < w-3; j++ {
+			score[i*w+j] = fastScore(data, w, j, i, threshold)
+		}
+	}
+	if nonmax {
+		return selectPeaks(score, w, h, 0, 0, 0)
+	}
+	var kps []KeyPoint
+	for i := range score {
+		if score[i] > 0 {
+			kps = append(kps, KeyPoint{X: float64(i % w), Y: float64(i / w), Response: score[i]})
+		}
+	}
+	return kps
+}
+
+// Descriptor 256 位二进制描述子
+type Descriptor [32]byte
+
+const (
+	orbPatchRadius = 15 // 计算方向的圆形区域半径
+	orbBorder      = 19 // 旋转后的采样点都落在图内所需的边界
+)
+
+// orbPattern rBRIEF 的 256 对采样点, 使用固定种子保证描述子可复现
+var orbPattern = func() (pattern [256][4]float64) {
+	r := rand.New(rand.NewSource(0x0b))
+	sample := func() float64 {
+		for {
+			v := r.NormFloat64() * 31.0 / 5.0
+			if math.Abs(v) <= 13 {
+				return v
+			}
+		}
+	}
+	for i := range pattern {
+		pattern[i] = [4]float64{sample(), sample(), sample(), sample()}
+	}
+	return
+}()
+
+// orientation 灰度质心法计算特征点方向
+func orientation(data []float64, w, x, y int) float64 {
+	var m01, m10 float64
+	r2 := orbPatchRadius * orbPatchRadius
+	for dy := -orbPatchRadius; dy <= orbPatchRadius; dy++ {
+		for dx := -orbPatchRadius; dx <= orbPatchRadius; dx++ {
+			if dx*dx+dy*dy > r2 {
+				continue
+			}
+			v := data[(y+dy)*w+x+dx]
+			m10 += float64(dx) * v
+			m01 += float64(dy) * v
+		}
+	}
+	return math.Atan2(m01, m10)
+}
+
+// describe 在平滑后的平面上计算旋转 BRIEF 描述子
+func describe(smooth []float64, w int, kp KeyPoint) Descriptor {
+	var d Descriptor
+	cosA, sinA := math.Cos(kp.Angle), math.Sin(kp.Angle)
+	at := func(px, py float64) float64 {
+		rx := cosA*px - sinA*py
+		ry := sinA*px + cosA*py
+		return smooth[int(math.Round(kp.Y+ry))*w+int(math.Round(kp.X+rx))]
+	}
+	for i, pt := range orbPattern {
+		if at(pt[0], pt[1]) < at(pt[2], pt[3]) {
+			d[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return d
+}
+
+// ComputeDescriptors 为给定的特征点计算方向和描述子
+// 离边界太近的点会被丢弃, 返回的特征点与描述子一一对应
+func (p *Picture) ComputeDescriptors(kps []KeyPoint) ([]KeyPoint, []Descriptor, error) {
+	if p.Img == nil {
+		return nil, nil, errors.New("image not loaded")
+	}
+	data, w, h := lumaPlane(p.Img)
+	min := p.Img.Bounds().Min
+	local := make([]KeyPoint, 0, len(kps))
+	for _, kp := range kps {
+		kp.X -= float64(min.X)
+		kp.Y -= float64(min.Y)
+		local = append(local, kp)
+	}
+	local, descs := computeDescriptors(data, w, h, local)
+	return offsetKeyPoints(p, local), descs, nil
+}
+
+func computeDescriptors(data []float64, w, h int, kps []KeyPoint) ([]KeyPoint, []Descriptor) {
+	smooth := gaussianBlur5(data, w, h)
+	outK := make([]KeyPoint, 0, len(kps))
+	outD := make([]Descriptor, 0, len(kps))
+	for _, kp := range kps {
+		x, y := int(math.Round(kp.X)), int(math.Round(kp.Y))
+		if x < orbBorder || y < orbBorder || x >= w-orbBorder || y >= h-orbBorder {
+			continue
+		}
+		kp.X, kp.Y = float64(x), float64(y)
+		kp.Angle = orientation(data, w, x, y)
+		outK = append(outK, kp)
+		outD = append(outD, describe(smooth, w, kp))
+	}
+	return outK, outD
+}
+
+// ORB 检测 FAST 角点, 按 Harris 响应保留最好的 maxFeatures 个, 并计算旋转 BRIEF 描述子
+func (p *Picture) ORB(maxFeatures int) ([]KeyPoint, []Descriptor, error) {
+	if p.Img == nil {
+		return nil, nil, errors.New("image not loaded")
+	}
+	data, w, h := lumaPlane(p.Img)
+	kps := fastDetect(data, w, h, 20, true)
+
+	// 用 Harris 响应重新排序
+	harris := cornerResponse(data, w, h, CornerHarris, 7, 0.04)
+	for i := range kps {
+		kps[i].Response = harris[int(kps[i].Y)*w+int(kps[i].X)]
+	}
+	sort.SliceStable(kps, func(a, b int) bool { return kps[a].Response > kps[b].Response })
+
+	kps, descs := computeDescriptors(data, w, h, kps)
+	if maxFeatures > 0 && len(kps) > maxFeatures {
+		kps, descs = kps[:maxFeatures], descs[:maxFeatures]
+	}
+	return offsetKeyPoints(p, kps), descs, nil
+}
+
+// HammingDistance 两个描述子之间的汉明距离
+func HammingDistance(a, b Descriptor) int {
+	d := 0
+	for i := range a {
+		d += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return d
+}
+
+// DMatch 描述子匹配结果
+type DMatch struct {
+	Query    int // query 描述子的下标
+	Train    int // train 描述子的下标
+	Distance int
+}
+
+// bestTwo 返回距离最近和次近的下标及距离
+func bestTwo(d Descriptor, set []Descriptor) (best, bestDist, secondDist int) {
+	best, bestDist, secondDist = -1, math.MaxInt32, math.MaxInt32
+	for i, s := range set {
+		dist := HammingDistance(d, s)
+		if dist < bestDist {
+			secondDist = bestDist
+			best, bestDist = i, dist
+		} else if dist < secondDist {
+			secondDist = dist
+		}
+	}
+	return
+}
+
+// MatchDescriptors 暴力汉明距离匹配
+// ratio 在 (0, 1) 之间时做 Lowe 比值检验(最近距离 < ratio·次近距离); crossCheck 为 true 时要求双向互为最近
+func MatchDescriptors(query, train []Descriptor, ratio float64, crossCheck bool) []DMatch {
+	var matches []DMatch
+	for qi, q := range query {
+		ti, dist, second := bestTwo(q, train)
+		if ti < 0 {
+			continue
+		}
+		if ratio > 0 && ratio < 1 && second != math.MaxInt32 && float64(dist) >= ratio*float64(second) {
+			continue
+		}
+		if crossCheck {
+			if back, _, _ := bestTwo(train[ti], query); back != qi {
+				continue
+			}
+		}
+		matches = append(matches, DMatch{qi, ti, dist})
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].Distance < matches[b].Distance })
+	return matches
+}
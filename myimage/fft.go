@@ -0,0 +1,127 @@
+package myimage
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+/*
+快速傅里叶变换(基2), 供相关运算等内部使用
+*/
+
+// nextPow2 大于等于 n 的最小的 2 的幂
+func nextPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// fft1d 原地一维FFT, len(a) 必须是 2 的幂; invert 为 true 时做逆变换(含 1/n 归一化)
+func fft1d(a []complex128, invert bool) {
+	n := len(a)
+	// 位反转重排
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		ang := 2 * math.Pi / float64(length)
+		if invert {
+			ang = -ang
+		}
+		wl := cmplx.Rect(1, -ang)
+		for i := 0; i < n; i += length {
+			w := complex(1, 0)
+			half := length >> 1
+			for k := 0; k < half; k++ {
+				u := a[i+k]
+				v := a[i+k+half] * w
+				a[i+k] = u + v
+				a[i+k+half] = u - v
+				w *= wl
+			}
+		}
+	}
+	if invert {
+		inv := complex(1/float64(n), 0)
+		for i := range a {
+			a[i] *= inv
+		}
+	}
+}
+
+// fft2d 原地二维FFT, data 按行存储, w 和 h 都必须是 2 的幂
+func fft2d(data []complex128, w, h int, invert bool) {
+	for y := 0; y < h; y++ {
+		fft1d(data[y*w:(y+1)*w], invert)
+	}
+	col := make([]complex128, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			col[y] = data[y*w+x]
+		}
+		fft1d(col, invert)
+		for y := 0; y < h; y++ {
+			data[y*w+x] = col[y]
+		}
+	}
+}
+
+// correlate 计算 src 与核 k 的 valid 相关:
+// out(x, y) = Σ k(i, j) * src(x+i, y+j), 输出大小 (sw-kw+1)x(sh-kh+1)
+// 核较小时直接计算, 较大时使用FFT
+func correlate(src []float64, sw, sh int, k []float64, kw, kh int) []float64 {
+	ow, oh := sw-kw+1, sh-kh+1
+	out := make([]float64, ow*oh)
+	if kw*kh <= 81 {
+		for y := 0; y < oh; y++ {
+			for x := 0; x < ow; x++ {
+				var s float64
+				for j := 0; j < kh; j++ {
+					row := src[(y+j)*sw+x:]
+					krow := k[j*kw : (j+1)*kw]
+					for i, kv := range krow {
+						s += kv * row[i]
+					}
+				}
+				out[y*ow+x] = s
+			}
+		}
+		return out
+	}
+
+	// 频域相关: F(src) * conj(F(k)), 尺寸不小于 src 时 valid 区域不会发生回绕
+	pw, ph := nextPow2(sw), nextPow2(sh)
+	fs := make([]complex128, pw*ph)
+	fk := make([]complex128, pw*ph)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			fs[y*pw+x] = complex(src[y*sw+x], 0)
+		}
+	}
+	for y := 0; y < kh; y++ {
+		for x := 0; x < kw; x++ {
+			fk[y*pw+x] = complex(k[y*kw+x], 0)
+		}
+	}
+	fft2d(fs, pw, ph, false)
+	fft2d(fk, pw, ph, false)
+	for i := range fs {
+		fs[i] *= cmplx.Conj(fk[i])
+	}
+	fft2d(fs, pw, ph, true)
+	for y := 0; y < oh; y++ {
+		for x := 0; x < ow; x++ {
+			out[y*ow+x] = real(fs[y*pw+x])
+		}
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+/*
+高精度: 16 位图片(*image.Gray16、*image.RGBA64 等)在处理后保持 16 位;
+FloatImage 以 float32 保存像素, 连续处理时中间结果不量化, 只在保存时才转换为 8 位或 16 位
+*/
+
+// BitDepth 每个通道的精度
+type BitDepth uint8
+
+const (
+	Depth8     BitDepth = iota // 8 位, *image.Gray / *image.RGBA
+	Depth16                    // 16 位, *image.Gray16 / *image.RGBA64
+	DepthFloat                 // float32, *FloatImage
+)
+
+// String 精度名称
+func (d BitDepth) String() string {
+	switch d {
+	case Depth16:
+		return "16"
+	case DepthFloat:
+		return "float"
+	}
+	return "8"
+}
+
+// ParseBitDepth 按名称解析: 8、16、float
+func ParseBitDepth(name string) (BitDepth, error) {
+	switch name {
+	case "8":
+		return Depth8, nil
+	case "16":
+		return Depth16, nil
+	case "float", "32":
+		return DepthFloat, nil
+	}
+	return Depth8, fmt.Errorf("unknown bit depth %q", name)
+}
+
+// imageDepth 图片的精度, 按颜色模型判断
+func imageDepth(img image.Image) BitDepth {
+	if _, ok := img.(*FloatImage); ok {
+		return DepthFloat
+	}
+	switch img.ColorModel() {
+	case color.Gray16Model, color.RGBA64Model, color.NRGBA64Model:
+		return Depth16
+	}
+	return Depth8
+}
+
+// FloatImage float32 精度的图片, 实现 image.Image
+// Channels 为 1 时是灰度图(不透明); 为 4 时按 R、G、B、A 交错存储, 颜色为预乘 alpha 的值
+// 取值范围通常为 0~1, 处理过程中允许超出, 读取像素(At)和保存时才裁剪
+type FloatImage struct {
+	Pix      []float32
+	Channels int
+	Stride   int
+	Rect     image.Rectangle
+}
+
+// NewFloatImage 创建全为 0 的 FloatImage, channels 为 1 或 4
+func NewFloatImage(r image.Rectangle, channels int) *FloatImage {
+	return &FloatImage{
+		Pix:      make([]float32, channels*r.Dx()*r.Dy()),
+		Channels: channels,
+		Stride:   channels * r.Dx(),
+		Rect:     r,
+	}
+}
+
+// ColorModel 实现 image.Image, 按 16 位输出, 因此保存为 png 时是 16 位
+func (f *FloatImage) ColorModel() color.Model {
+	if f.Channels == 1 {
+		return color.Gray16Model
+	}
+	return color.RGBA64Model
+}
+
+// Bounds 实现 image.Image
+func (f *FloatImage) Bounds() image.Rectangle {
+	return f.Rect
+}
+
+// PixOffset (x, y) 处像素第一个通道在 Pix 中的下标
+func (f *FloatImage) PixOffset(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Stride + (x-f.Rect.Min.X)*f.Channels
+}
+
+// to16 0~1 的值转为 16 位
+func to16(v float32) uint16 {
+	return uint16(math.Max(0, math.Min(65535, float64(v)*65535+0.5)))
+}
+
+// At 实现 image.Image, 结果裁剪并量化到 16 位
+func (f *FloatImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		if f.Channels == 1 {
+			return color.Gray16{}
+		}
+		return color.RGBA64{}
+	}
+	i := f.PixOffset(x, y)
+	if f.Channels == 1 {
+		return color.Gray16{to16(f.Pix[i])}
+	}
+	a := to16(f.Pix[i+3])
+	c := color.RGBA64{to16(f.Pix[i]), to16(f.Pix[i+1]), to16(f.Pix[i+2]), a}
+	// 预乘的颜色不能超过 alpha
+	if c.R > a {
+		c.R = a
+	}
+	if c.G > a {
+		c.G = a
+	}
+	if c.B > a {
+		c.B = a
+	}
+	return c
+}
+
+// SubImage 与原图共享像素的子图
+func (f *FloatImage) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(f.Rect)
+	if r.Empty() {
+		return &FloatImage{Channels: f.Channels}
+	}
+	i := f.PixOffset(r.Min.X, r.Min.Y)
+	return &FloatImage{Pix: f.Pix[i:], Channels: f.Channels, Stride: f.Stride, Rect: r}
+}
+
+// ToFloatImage 任意图片转为以 (0, 0) 为原点的 FloatImage, 灰度图为 1 个通道, 其余为 4 个通道
+func ToFloatImage(img image.Image) *FloatImage {
+	planes, alpha, w, h := colorPlanes(img)
+	return planesToImage(planes, alpha, w, h, DepthFloat).(*FloatImage)
+}
+
+// floatPlanes FloatImage 的 colorPlanes, 直接读取 float32, 不经过 16 位
+func floatPlanes(f *FloatImage) (planes [][]float64, alpha []float64, w, h int) {
+	w, h = f.Rect.Dx(), f.Rect.Dy()
+	nc := f.Channels
+	if nc == 4 {
+		nc = 3
+	}
+	for c := 0; c < nc; c++ {
+		planes = append(planes, make([]float64, w*h))
+	}
+	alpha = make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		row := f.Pix[i*f.Stride:]
+		for j := 0; j < w; j++ {
+			px := row[j*f.Channels:]
+			for c := 0; c < nc; c++ {
+				planes[c][i*w+j] = float64(px[c]) * 255
+			}
+			if f.Channels == 4 {
+				alpha[i*w+j] = float64(px[3]) * 255
+			} else {
+				alpha[i*w+j] = 255
+			}
+		}
+	}
+	return
+}
+
+// Depth 图片的精度
+func (p *Picture) Depth() BitDepth {
+	if p.Img == nil {
+		return Depth8
+	}
+	return imageDepth(p.Img)
+}
+
+// ConvertDepth 转换精度: 转为 DepthFloat 后可以连续处理而不累积舍入误差, 保存时再量化
+func (p *Picture) ConvertDepth(p1 *Picture, depth BitDepth) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	switch depth {
+	case Depth8, Depth16, DepthFloat:
+	default:
+		return fmt.Errorf("unknown bit depth %d", depth)
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	p.setResult(p1, planesToImage(planes, alpha, w, h, depth))
+	return
+}
+
+// cloneImage 复制图片, 保持精度, 原点移到 (0, 0)
+func cloneImage(img image.Image) image.Image {
+	b := img.Bounds()
+	r := image.Rect(0, 0, b.Dx(), b.Dy())
+	var dst draw.Image
+	switch imageDepth(img) {
+	case DepthFloat:
+		f := img.(*FloatImage)
+		out := NewFloatImage(r, f.Channels)
+		n := f.Channels * b.Dx()
+		for i := 0; i < b.Dy(); i++ {
+			copy(out.Pix[i*out.Stride:i*out.Stride+n], f.Pix[i*f.Stride:])
+		}
+		return out
+	case Depth16:
+		if isGrayImage(img) {
+			dst = image.NewGray16(r)
+		} else {
+			dst = image.NewRGBA64(r)
+		}
+	default:
+		dst = image.NewRGBA(r)
+	}
+	draw.Draw(dst, r, img, b.Min, draw.Src)
+	return dst
+}
+
+// flipImage 翻转图片, horizontal 为 true 时左右镜像, 否则上下镜像; 在 cloneImage 的结果上交换像素, 保持精度
+func flipImage(img image.Image, horizontal bool) image.Image {
+	out := cloneImage(img)
+	b := out.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// swap 交换 (j, i) 与其镜像位置的像素
+	var swap func(i, j, i2, j2 int)
+	switch m := out.(type) {
+	case *FloatImage:
+		swap = func(i, j, i2, j2 int) {
+			a, b := m.Pix[m.PixOffset(j, i):], m.Pix[m.PixOffset(j2, i2):]
+			for c := 0; c < m.Channels; c++ {
+				a[c], b[c] = b[c], a[c]
+			}
+		}
+	default:
+		var pix []uint8
+		var stride, size int
+		switch m := out.(type) {
+		case *image.RGBA:
+			pix, stride, size = m.Pix, m.Stride, 4
+		case *image.RGBA64:
+			pix, stride, size = m.Pix, m.Stride, 8
+		case *image.Gray16:
+			pix, stride, size = m.Pix, m.Stride, 2
+		}
+		swap = func(i, j, i2, j2 int) {
+			a, b := pix[i*stride+j*size:], pix[i2*stride+j2*size:]
+			for c := 0; c < size; c++ {
+				a[c], b[c] = b[c], a[c]
+			}
+		}
+	}
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			i2, j2 := i, w-1-j
+			if !horizontal {
+				i2, j2 = h-1-i, j
+			}
+			// 每对像素只交换一次
+			if i2*w+j2 > i*w+j {
+				swap(i, j, i2, j2)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package myimage
+
+/*
+内置 5x7 点阵字体, 用于在图片上写标签和标题(只支持 ASCII, 小写字母按大写显示)
+*/
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// glyphs 每个字符 7 行, 每行 5 列, '#' 为前景
+var glyphs = map[rune][glyphHeight]string{
+	'A':  {" ### ", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'B':  {"#### ", "#   #", "#   #", "#### ", "#   #", "#   #", "#### "},
+	'C':  {" ### ", "#   #", "#    ", "#    ", "#    ", "#   #", " ### "},
+	'D':  {"#### ", "#   #", "#   #", "#   #", "#   #", "#   #", "#### "},
+	'E':  {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#####"},
+	'F':  {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#    "},
+	'G':  {" ### ", "#   #", "#    ", "# ###", "#   #", "#   #", " ####"},
+	'H':  {"#   #", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'I':  {" ### ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'J':  {"  ###", "   # ", "   # ", "   # ", "   # ", "#  # ", " ##  "},
+	'K':  {"#   #", "#  # ", "# #  ", "##   ", "# #  ", "#  # ", "#   #"},
+	'L':  {"#    ", "#    ", "#    ", "#    ", "#    ", "#    ", "#####"},
+	'M':  {"#   #", "## ##", "# # #", "# # #", "#   #", "#   #", "#   #"},
+	'N':  {"#   #", "#   #", "##  #", "# # #", "#  ##", "#   #", "#   #"},
+	'O':  {" ### ", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'P':  {"#### ", "#   #", "#   #", "#### ", "#    ", "#    ", "#    "},
+	'Q':  {" ### ", "#   #", "#   #", "#   #", "# # #", "#  # ", " ## #"},
+	'R':  {"#### ", "#   #", "#   #", "#### ", "# #  ", "#  # ", "#   #"},
+	'S':  {" ####", "#    ", "#    ", " ### ", "    #", "    #", "#### "},
+	'T':  {"#####", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U':  {"#   #", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'V':  {"#   #", "#   #", "#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W':  {"#   #", "#   #", "#   #", "# # #", "# # #", "# # #", " # # "},
+	'X':  {"#   #", "#   #", " # # ", "  #  ", " # # ", "#   #", "#   #"},
+	'Y':  {"#   #", "#   #", " # # ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'Z':  {"#####", "    #", "   # ", "  #  ", " #   ", "#    ", "#####"},
+	'0':  {" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	'1':  {"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'2':  {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3':  {"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	'4':  {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5':  {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6':  {"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7':  {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8':  {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'9':  {" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+	' ':  {"     ", "     ", "     ", "     ", "     ", "     ", "     "},
+	'.':  {"     ", "     ", "     ", "     ", "     ", " ##  ", " ##  "},
+	',':  {"     ", "     ", "     ", "     ", " ##  ", "  #  ", " #   "},
+	':':  {"     ", " ##  ", " ##  ", "     ", " ##  ", " ##  ", "     "},
+	';':  {"     ", " ##  ", " ##  ", "     ", " ##  ", "  #  ", " #   "},
+	'-':  {"     ", "     ", "     ", "#####", "     ", "     ", "     "},
+	'_':  {"     ", "     ", "     ", "     ", "     ", "     ", "#####"},
+	'+':  {"     ", "  #  ", "  #  ", "#####", "  #  ", "  #  ", "     "},
+	'=':  {"     ", "     ", "#####", "     ", "#####", "     ", "     "},
+	'/':  {"     ", "    #", "   # ", "  #  ", " #   ", "#    ", "     "},
+	'\\': {"     ", "#    ", " #   ", "  #  ", "   # ", "    #", "     "},
+	'(':  {"   # ", "  #  ", " #   ", " #   ", " #   ", "  #  ", "   # "},
+	')':  {" #   ", "  #  ", "   # ", "   # ", "   # ", "  #  ", " #   "},
+	'[':  {" ### ", " #   ", " #   ", " #   ", " #   ", " #   ", " ### "},
+	']':  {" ### ", "   # ", "   # ", "   # ", "   # ", "   # ", " ### "},
+	'<':  {"   # ", "  #  ", " #   ", "#    ", " #   ", "  #  ", "   # "},
+	'>':  {" #   ", "  #  ", "   # ", "    #", "   # ", "  #  ", " #   "},
+	'!':  {"  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "     ", "  #  "},
+	'?':  {" ### ", "#   #", "    #", "   # ", "  #  ", "     ", "  #  "},
+	'\'': {"  #  ", "  #  ", " #   ", "     ", "     ", "     ", "     "},
+	'"':  {" # # ", " # # ", " # # ", "     ", "     ", "     ", "     "},
+	'#':  {" # # ", " # # ", "#####", " # # ", "#####", " # # ", " # # "},
+	'%':  {"##   ", "##  #", "   # ", "  #  ", " #   ", "#  ##", "   ##"},
+	'&':  {" ##  ", "#  # ", "# #  ", " #   ", "# # #", "#  # ", " ## #"},
+	'*':  {"     ", "  #  ", "# # #", " ### ", "# # #", "  #  ", "     "},
+	'@':  {" ### ", "#   #", "    #", " ## #", "# # #", "# # #", " ### "},
+	'$':  {"  #  ", " ####", "# #  ", " ### ", "  # #", "#### ", "  #  "},
+	'|':  {"  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  "},
+}
+
+// glyphFor 取字符的点阵, 小写转大写, 不支持的字符显示为 '?'
+func glyphFor(r rune) [glyphHeight]string {
+	if r >= 'a' && r <= 'z' {
+		r -= 'a' - 'A'
+	}
+	if g, ok := glyphs[r]; ok {
+		return g
+	}
+	return glyphs['?']
+}
@@ -0,0 +1,262 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+感知哈希: 用于查找只因重新压缩或缩放而不同的近似重复图片
+*/
+
+// ImageHash 64 位图片哈希
+type ImageHash uint64
+
+// Distance 两个哈希之间的汉明距离
+func (h ImageHash) Distance(other ImageHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+// String 16 位十六进制表示
+func (h ImageHash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// ParseImageHash 从十六进制字符串解析哈希
+func ParseImageHash(s string) (ImageHash, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	return ImageHash(v), err
+}
+
+// HashMethod 哈希算法
+type HashMethod uint8
+
+const (
+	AverageHash    HashMethod = iota // 均值哈希
+	DifferenceHash                   // 差值哈希
+	PerceptualHash                   // DCT 感知哈希
+	WaveletHash                      // Haar 小波哈希
+)
+
+// ParseHashMethod 从名字解析哈希算法: ahash / dhash / phash / whash
+func ParseHashMethod(name string) (HashMethod, error) {
+	switch strings.ToLower(name) {
+	case "ahash", "average":
+		return AverageHash, nil
+	case "dhash", "difference":
+		return DifferenceHash, nil
+	case "phash", "perceptual":
+		return PerceptualHash, nil
+	case "whash", "wavelet":
+		return WaveletHash, nil
+	default:
+		return 0, errors.New("hash method only ahash, dhash, phash or whash")
+	}
+}
+
+// bitsAbove 值大于阈值的位置 1
+func bitsAbove(vals []float64, threshold float64) ImageHash {
+	var h ImageHash
+	for i, v := range vals {
+		if v > threshold {
+			h |= 1 << uint(i)
+		}
+	}
+	return h
+}
+
+// median 中位数
+func median(vals []float64) float64 {
+	tmp := append([]float64(nil), vals...)
+	sort.Float64s(tmp)
+	n := len(tmp)
+	if n%2 == 1 {
+		return tmp[n/2]
+	}
+	return (tmp[n/2-1] + tmp[n/2]) / 2
+}
+
+// Hash 计算图片哈希
+func (p *Picture) Hash(method HashMethod) (ImageHash, error) {
+	if p.Img == nil {
+		return 0, errors.New("image not loaded")
+	}
+	data, w, h := lumaPlane(p.Img)
+	if w == 0 || h == 0 {
+		return 0, errors.New("empty image")
+	}
+	switch method {
+	case AverageHash:
+		small := resizePlane(data, w, h, 8, 8)
+		return bitsAbove(small, mean(small)), nil
+	case DifferenceHash:
+		// 9x8, 比较水平相邻像素
+		small := resizePlane(data, w, h, 9, 8)
+		var hash ImageHash
+		for i := 0; i < 8; i++ {
+			for j := 0; j < 8; j++ {
+				if small[i*9+j+1] > small[i*9+j] {
+					hash |= 1 << uint(i*8+j)
+				}
+			}
+		}
+		return hash, nil
+	case PerceptualHash:
+		// 32x32 做 DCT, 取左上角 8x8 低频系数与中位数比较
+		small := resizePlane(data, w, h, 32, 32)
+		coef := dct2d(small, 32)
+		low := make([]float64, 64)
+		for i := 0; i < 8; i++ {
+			for j := 0; j < 8; j++ {
+				low[i*8+j] = coef[i*32+j]
+			}
+		}
+		// 直流分量不参与中位数
+		return bitsAbove(low, median(low[1:])), nil
+	case WaveletHash:
+		// 64x64 做 3 级 Haar 分解, 取 8x8 低频与中位数比较
+		small := resizePlane(data, w, h, 64, 64)
+		for n := 64; n > 8; n /= 2 {
+			small = haarLowpass(small, n)
+		}
+		return bitsAbove(small, median(small)), nil
+	default:
+		return 0, errors.New("unknown hash method")
+	}
+}
+
+// AverageHash 均值哈希
+func (p *Picture) AverageHash() (ImageHash, error) {
+	return p.Hash(AverageHash)
+}
+
+// DifferenceHash 差值哈希
+func (p *Picture) DifferenceHash() (ImageHash, error) {
+	return p.Hash(DifferenceHash)
+}
+
+// PerceptualHash DCT 感知哈希
+func (p *Picture) PerceptualHash() (ImageHash, error) {
+	return p.Hash(PerceptualHash)
+}
+
+// WaveletHash Haar 小波哈希
+func (p *Picture) WaveletHash() (ImageHash, error) {
+	return p.Hash(WaveletHash)
+}
+
+// dct2d n x n 的二维 DCT-II
+func dct2d(data []float64, n int) []float64 {
+	cos := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for x := 0; x < n; x++ {
+			cos[k*n+x] = math.Cos(math.Pi / float64(n) * (float64(x) + 0.5) * float64(k))
+		}
+	}
+	tmp := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			var s float64
+			for x := 0; x < n; x++ {
+				s += data[i*n+x] * cos[k*n+x]
+			}
+			tmp[i*n+k] = s
+		}
+	}
+	out := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for j := 0; j < n; j++ {
+			var s float64
+			for y := 0; y < n; y++ {
+				s += tmp[y*n+j] * cos[k*n+y]
+			}
+			out[k*n+j] = s
+		}
+	}
+	return out
+}
+
+// haarLowpass n x n 平面做一级 Haar 分解, 返回 n/2 x n/2 的低频部分
+func haarLowpass(data []float64, n int) []float64 {
+	m := n / 2
+	out := make([]float64, m*m)
+	for i := 0; i < m; i++ {
+		for j := 0; j < m; j++ {
+			out[i*m+j] = (data[2*i*n+2*j] + data[2*i*n+2*j+1] + data[(2*i+1)*n+2*j] + data[(2*i+1)*n+2*j+1]) / 2
+		}
+	}
+	return out
+}
+
+// BKTree 以汉明距离为度量的 BK 树, 用于快速查找相近的哈希
+type BKTree struct {
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     ImageHash
+	ids      []int // 哈希相同的条目
+	children map[int]*bkNode
+}
+
+// Len 树中条目数量
+func (t *BKTree) Len() int {
+	return t.size
+}
+
+// Add 添加一个哈希, id 由调用方定义(如文件下标)
+func (t *BKTree) Add(hash ImageHash, id int) {
+	t.size++
+	if t.root == nil {
+		t.root = &bkNode{hash: hash, ids: []int{id}}
+		return
+	}
+	node := t.root
+	for {
+		d := node.hash.Distance(hash)
+		if d == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []int{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// Search 查找与 hash 距离不超过 maxDist 的全部条目 id
+func (t *BKTree) Search(hash ImageHash, maxDist int) []int {
+	var out []int
+	if t.root == nil {
+		return out
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := node.hash.Distance(hash)
+		if d <= maxDist {
+			out = append(out, node.ids...)
+		}
+		// 三角不等式: 只需访问距离在 [d-maxDist, d+maxDist] 的子树
+		for cd, child := range node.children {
+			if cd >= d-maxDist && cd <= d+maxDist {
+				stack = append(stack, child)
+			}
+		}
+	}
+	sort.Ints(out)
+	return out
+}
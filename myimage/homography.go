@@ -0,0 +1,267 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+/*
+单应性矩阵估计(RANSAC)与透视变换, 用于图像配准
+*/
+
+// Homography 3x3 单应性矩阵, 按行存储
+type Homography [9]float64
+
+// Apply 变换一个点
+func (m Homography) Apply(x, y float64) (float64, float64) {
+	w := m[6]*x + m[7]*y + m[8]
+	return (m[0]*x + m[1]*y + m[2]) / w, (m[3]*x + m[4]*y + m[5]) / w
+}
+
+// Mul 矩阵乘法 m·n, 即先做 n 再做 m 的变换
+func (m Homography) Mul(n Homography) (r Homography) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i*3+j] += m[i*3+k] * n[k*3+j]
+			}
+		}
+	}
+	return
+}
+
+// Inverse 逆矩阵
+func (m Homography) Inverse() (Homography, error) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+	if math.Abs(det) < 1e-12 {
+		return Homography{}, errors.New("homography is singular")
+	}
+	inv := Homography{
+		m[4]*m[8] - m[5]*m[7], m[2]*m[7] - m[1]*m[8], m[1]*m[5] - m[2]*m[4],
+		m[5]*m[6] - m[3]*m[8], m[0]*m[8] - m[2]*m[6], m[2]*m[3] - m[0]*m[5],
+		m[3]*m[7] - m[4]*m[6], m[1]*m[6] - m[0]*m[7], m[0]*m[4] - m[1]*m[3],
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, nil
+}
+
+// solveLinear 高斯消元(列主元)解 a·x = b, a 为 n x n 按行存储; 奇异时返回 false
+func solveLinear(a []float64, b []float64, n int) ([]float64, bool) {
+	for col := 0; col < n; col++ {
+		piv := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r*n+col]) > math.Abs(a[piv*n+col]) {
+				piv = r
+			}
+		}
+		if math.Abs(a[piv*n+col]) < 1e-12 {
+			return nil, false
+		}
+		if piv != col {
+			for k := 0; k < n; k++ {
+				a[col*n+k], a[piv*n+k] = a[piv*n+k], a[col*n+k]
+			}
+			b[col], b[piv] = b[piv], b[col]
+		}
+		for r := col + 1; r < n; r++ {
+			f := a[r*n+col] / a[col*n+col]
+			for k := col; k < n; k++ {
+				a[r*n+k] -= f * a[col*n+k]
+			}
+			b[r] -= f * b[col]
+		}
+	}
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		s := b[r]
+		for k := r + 1; k < n; k++ {
+			s -= a[r*n+k] * x[k]
+		}
+		x[r] = s / a[r*n+r]
+	}
+	return x, true
+}
+
+// normalizePoints Hartley 归一化: 平移到质心并缩放到平均距离 √2
+func normalizePoints(pts []PointF) ([]PointF, Homography) {
+	var cx, cy float64
+	for _, p := range pts {
+		cx += p.X
+		cy += p.Y
+	}
+	cx /= float64(len(pts))
+	cy /= float64(len(pts))
+	var d float64
+	for _, p := range pts {
+		d += math.Hypot(p.X-cx, p.Y-cy)
+	}
+	d /= float64(len(pts))
+	s := 1.0
+	if d > 0 {
+		s = math.Sqrt2 / d
+	}
+	out := make([]PointF, len(pts))
+	for i, p := range pts {
+		out[i] = PointF{(p.X - cx) * s, (p.Y - cy) * s}
+	}
+	return out, Homography{s, 0, -s * cx, 0, s, -s * cy, 0, 0, 1}
+}
+
+// fitHomography 最小二乘(DLT, h33 = 1)拟合, 至少需要 4 对点
+func fitHomography(src, dst []PointF) (Homography, bool) {
+	ns, ts := normalizePoints(src)
+	nd, td := normalizePoints(dst)
+	// 法方程 AᵀA·h = Aᵀb
+	ata := make([]float64, 64)
+	atb := make([]float64, 8)
+	add := func(row [8]float64, v float64) {
+		for i := 0; i < 8; i++ {
+			for j := 0; j < 8; j++ {
+				ata[i*8+j] += row[i] * row[j]
+			}
+			atb[i] += row[i] * v
+		}
+	}
+	for i := range ns {
+		x, y, u, v := ns[i].X, ns[i].Y, nd[i].X, nd[i].Y
+		add([8]float64{x, y, 1, 0, 0, 0, -u * x, -u * y}, u)
+		add([8]float64{0, 0, 0, x, y, 1, -v * x, -v * y}, v)
+	}
+	h, ok := solveLinear(ata, atb, 8)
+	if !ok {
+		return Homography{}, false
+	}
+	hn := Homography{h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7], 1}
+	tdInv, err := td.Inverse()
+	if err != nil {
+		return Homography{}, false
+	}
+	m := tdInv.Mul(hn).Mul(ts)
+	if m[8] == 0 {
+		return Homography{}, false
+	}
+	for i := range m {
+		m[i] /= m[8]
+	}
+	return m, true
+}
+
+// EstimateHomography 使用 RANSAC 估计把 src 映射到 dst 的单应性矩阵
+// threshold 为内点的重投影误差阈值(像素), 返回矩阵和每对点是否为内点
+func EstimateHomography(src, dst []PointF, iterations int, threshold float64, seed int64) (Homography, []bool, error) {
+	if len(src) != len(dst) {
+		return Homography{}, nil, errors.New("src and dst must have the same length")
+	}
+	if len(src) < 4 {
+		return Homography{}, nil, errors.New("at least 4 point pairs are required")
+	}
+	r := rand.New(rand.NewSource(seed))
+	n := len(src)
+	countInliers := func(m Homography, mask []bool) int {
+		c := 0
+		for i := 0; i < n; i++ {
+			x, y := m.Apply(src[i].X, src[i].Y)
+			in := math.Hypot(x-dst[i].X, y-dst[i].Y) <= threshold
+			if mask != nil {
+				mask[i] = in
+			}
+			if in {
+				c++
+			}
+		}
+		return c
+	}
+
+	var best Homography
+	bestCount := -1
+	sub := make([]int, 4)
+	s4, d4 := make([]PointF, 4), make([]PointF, 4)
+	for it := 0; it < iterations; it++ {
+		// 随机取 4 个不同的点
+		for k := 0; k < 4; {
+			c := r.Intn(n)
+			dup := false
+			for t := 0; t < k; t++ {
+				if sub[t] == c {
+					dup = true
+				}
+			}
+			if !dup {
+				sub[k] = c
+				s4[k], d4[k] = src[c], dst[c]
+				k++
+			}
+		}
+		m, ok := fitHomography(s4, d4)
+		if !ok {
+			continue
+		}
+		if c := countInliers(m, nil); c > bestCount {
+			best, bestCount = m, c
+		}
+	}
+	if bestCount < 4 {
+		return Homography{}, nil, errors.New("no homography found")
+	}
+
+	// 用全部内点重新拟合
+	mask := make([]bool, n)
+	countInliers(best, mask)
+	var si, di []PointF
+	for i, in := range mask {
+		if in {
+			si = append(si, src[i])
+			di = append(di, dst[i])
+		}
+	}
+	if m, ok := fitHomography(si, di); ok && countInliers(m, nil) >= bestCount {
+		best = m
+	}
+	countInliers(best, mask)
+	return best, mask, nil
+}
+
+// WarpPerspective 透视变换, 输出大小为 w x h, 原图中 (x, y) 映射到输出的 m.Apply(x, y)
+// 超出原图的部分透明; 插值是否在线性光中进行由 LinearLight.Resample 决定, 结果保持原图精度
+func (p *Picture) WarpPerspective(p1 *Picture, m Homography, w, h int) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if w < 0 || h < 0 {
+		return errors.New("size must not be negative")
+	}
+	inv, err := m.Inverse()
+	if err != nil {
+		return
+	}
+	linear := LinearLight.Resample
+	planes, alpha, sw, sh := p.planesFor(linear)
+	// 灰度图也输出 RGBA, 以保留透明的空白区域
+	if len(planes) == 1 {
+		planes = [][]float64{planes[0], planes[0], planes[0]}
+	}
+	src := append(planes, alpha)
+	dst := make([][]float64, len(src))
+	for c := range dst {
+		dst[c] = make([]float64, w*h)
+	}
+	parallelRows(h, func(y0, y1 int) {
+		for i := y0; i < y1; i++ {
+			for j := 0; j < w; j++ {
+				x, y := inv.Apply(float64(j), float64(i))
+				// 超出原图像素边界, 边缘半个像素内取边缘值
+				if x < -0.5 || y < -0.5 || x > float64(sw)-0.5 || y > float64(sh)-0.5 {
+					continue
+				}
+				for c, data := range src {
+					dst[c][i*w+j] = bilinearAt(data, sw, sh, x, y)
+				}
+			}
+		}
+	})
+	p.setResult(p1, p.imageFor(dst[:3], dst[3], w, h, linear))
+	return
+}
@@ -0,0 +1,422 @@
+package myimage
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+/*
+图像修复(inpainting): 用周围的内容填充掩码区域, 用于去除水印、灰尘和坏点
+Telea 方法按快速行进(fast marching)的顺序由外向内逐像素插值, 速度快, 适合细小的划痕;
+样本块(exemplar)方法按优先级逐块复制最相似的已知图像块, 能延续纹理和结构, 适合较大的空洞
+*/
+
+// InpaintMethod 修复方法
+type InpaintMethod uint8
+
+const (
+	InpaintTelea    InpaintMethod = iota // 快速行进 + 加权平均
+	InpaintExemplar                      // 基于样本块的填充(Criminisi)
+)
+
+// ParseInpaintMethod 按名称解析: telea、exemplar
+func ParseInpaintMethod(name string) (InpaintMethod, error) {
+	switch strings.ToLower(name) {
+	case "telea":
+		return InpaintTelea, nil
+	case "exemplar":
+		return InpaintExemplar, nil
+	}
+	return InpaintTelea, fmt.Errorf("unknown inpaint method %q", name)
+}
+
+// MaskFromPolygons 由多边形生成 w x h 的掩码图, 多边形内部(奇偶规则, 以像素中心判断)为白色
+// 坐标以像素边界为准, 与标注中的多边形一致
+func MaskFromPolygons(w, h int, polygons [][]PointF) *Picture {
+	mask := image.NewGray(image.Rect(0, 0, w, h))
+	for _, poly := range polygons {
+		n := len(poly)
+		if n < 3 {
+			continue
+		}
+		for i := 0; i < h; i++ {
+			cy := float64(i) + 0.5
+			// 扫描线与各边的交点
+			var xs []float64
+			for k := 0; k < n; k++ {
+				a, b := poly[k], poly[(k+1)%n]
+				if (a.Y <= cy) != (b.Y <= cy) {
+					xs = append(xs, a.X+(cy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+				}
+			}
+			for j := 0; j < w; j++ {
+				cx, inside := float64(j)+0.5, false
+				for _, x := range xs {
+					if x < cx {
+						inside = !inside
+					}
+				}
+				if inside {
+					mask.Pix[i*mask.Stride+j] = 255
+				}
+			}
+		}
+	}
+	return &Picture{Img: mask}
+}
+
+// Inpaint 修复 mask 中白色(亮度 >= 128)的区域, mask 与图片大小相同
+// radius 为 Telea 方法的邻域半径(0 时为 5), 或样本块方法的块半径(0 时为 4, 即 9x9 的块)
+func (p *Picture) Inpaint(p1 *Picture, mask *Picture, method InpaintMethod, radius int) (err error) {
+	if p.Img == nil || mask == nil || mask.Img == nil {
+		return errors.New("image not loaded")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	if mw, mh := mask.GetSize(); mw != w || mh != h {
+		return errors.New("mask size differs from image size")
+	}
+	m, _, _ := lumaPlane(mask.Img)
+	unknown := make([]bool, w*h)
+	for i, v := range m {
+		unknown[i] = v >= 128
+	}
+	// alpha 作为额外的通道一起修复
+	channels := append(planes, alpha)
+	switch method {
+	case InpaintTelea:
+		if radius <= 0 {
+			radius = 5
+		}
+		inpaintTelea(channels, unknown, w, h, radius)
+	case InpaintExemplar:
+		if radius <= 0 {
+			radius = 4
+		}
+		inpaintExemplar(channels, unknown, w, h, radius)
+	default:
+		return fmt.Errorf("unknown inpaint method %d", method)
+	}
+	p.setResult(p1, planesToImage(channels[:len(planes)], channels[len(planes)], w, h, imageDepth(p.Img)))
+	return
+}
+
+// marchItem 快速行进的窄带元素
+type marchItem struct {
+	t   float64
+	idx int
+}
+
+// marchHeap 按到达时间 t 排序的最小堆
+type marchHeap []marchItem
+
+func (q marchHeap) Len() int            { return len(q) }
+func (q marchHeap) Less(i, j int) bool  { return q[i].t < q[j].t }
+func (q marchHeap) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *marchHeap) Push(x interface{}) { *q = append(*q, x.(marchItem)) }
+func (q *marchHeap) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// eikonal 由 x、y 方向上较小的已知到达时间求解 |∇T| = 1
+func eikonal(a, b float64) float64 {
+	if math.IsInf(a, 1) && math.IsInf(b, 1) {
+		return math.Inf(1)
+	}
+	if math.Abs(a-b) >= 1 {
+		return math.Min(a, b) + 1
+	}
+	return (a + b + math.Sqrt(2-(a-b)*(a-b))) / 2
+}
+
+// inpaintTelea Telea 快速行进修复, 原地修改 channels
+func inpaintTelea(channels [][]float64, unknown []bool, w, h, radius int) {
+	known := make([]bool, w*h)
+	t := make([]float64, w*h)
+	for i := range t {
+		known[i] = !unknown[i]
+		if unknown[i] {
+			t[i] = math.Inf(1)
+		}
+	}
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= w || y >= h || !known[y*w+x] {
+			return math.Inf(1)
+		}
+		return t[y*w+x]
+	}
+	arrival := func(x, y int) float64 {
+		return eikonal(math.Min(at(x-1, y), at(x+1, y)), math.Min(at(x, y-1), at(x, y+1)))
+	}
+	q := &marchHeap{}
+	queued := make([]bool, w*h)
+	push := func(x, y int) {
+		if x < 0 || y < 0 || x >= w || y >= h || known[y*w+x] {
+			return
+		}
+		k := y*w + x
+		if nt := arrival(x, y); nt < t[k] {
+			t[k] = nt
+			heap.Push(q, marchItem{nt, k})
+			queued[k] = true
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if known[y*w+x] {
+				continue
+			}
+			if !math.IsInf(arrival(x, y), 1) {
+				push(x, y)
+			}
+		}
+	}
+
+	r2 := radius * radius
+	for q.Len() > 0 {
+		it := heap.Pop(q).(marchItem)
+		k := it.idx
+		if known[k] || it.t > t[k] {
+			continue
+		}
+		x, y := k%w, k/w
+		// 到达时间的梯度, 即修复前沿的法向
+		gx, gy := 0.0, 0.0
+		if a, b := at(x+1, y), at(x-1, y); !math.IsInf(a, 1) && !math.IsInf(b, 1) {
+			gx = (a - b) / 2
+		} else if !math.IsInf(a, 1) {
+			gx = a - t[k]
+		} else if !math.IsInf(b, 1) {
+			gx = t[k] - b
+		}
+		if a, b := at(x, y+1), at(x, y-1); !math.IsInf(a, 1) && !math.IsInf(b, 1) {
+			gy = (a - b) / 2
+		} else if !math.IsInf(a, 1) {
+			gy = a - t[k]
+		} else if !math.IsInf(b, 1) {
+			gy = t[k] - b
+		}
+
+		sums := make([]float64, len(channels))
+		var wsum float64
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				nx, ny := x+dx, y+dy
+				if dx*dx+dy*dy > r2 || nx < 0 || ny < 0 || nx >= w || ny >= h || !known[ny*w+nx] || (dx == 0 && dy == 0) {
+					continue
+				}
+				n := ny*w + nx
+				d2 := float64(dx*dx + dy*dy)
+				// 方向项: 沿法向的邻居更重要; 距离项; 等值线项: 与当前前沿距离相近的邻居更重要
+				dir := math.Abs(-float64(dx)*gx-float64(dy)*gy) / math.Sqrt(d2)
+				if dir == 0 {
+					dir = 1e-6
+				}
+				wt := dir / d2 / (1 + math.Abs(t[n]-t[k]))
+				for c, ch := range channels {
+					sums[c] += wt * ch[n]
+				}
+				wsum += wt
+			}
+		}
+		if wsum > 0 {
+			for c, ch := range channels {
+				ch[k] = sums[c] / wsum
+			}
+		}
+		known[k] = true
+		push(x-1, y)
+		push(x+1, y)
+		push(x, y-1)
+		push(x, y+1)
+	}
+}
+
+// inpaintExemplar 基于样本块的修复(Criminisi 等), 原地修改 channels
+// 每次选优先级(置信度 x 结构强度)最高的前沿像素, 在附近搜索与其已知部分差异最小的完整已知块, 复制未知部分
+func inpaintExemplar(channels [][]float64, unknown []bool, w, h, r int) {
+	filled := make([]bool, w*h)
+	conf := make([]float64, w*h)
+	minX, minY, maxX, maxY := w, h, -1, -1
+	for i := range filled {
+		filled[i] = !unknown[i]
+		if filled[i] {
+			conf[i] = 1
+			continue
+		}
+		x, y := i%w, i/w
+		minX, minY = minInt(minX, x), minInt(minY, y)
+		maxX, maxY = maxInt(maxX, x), maxInt(maxY, y)
+	}
+	if maxX < 0 {
+		return
+	}
+	// 搜索范围: 空洞外接矩形向外扩展, 兼顾速度和候选块的数量
+	margin := maxInt(maxX-minX, maxY-minY) + 4*r + 8
+	luma := make([]float64, w*h)
+	for i := range luma {
+		luma[i] = channels[0][i]
+		if len(channels) >= 4 {
+			luma[i] = 0.299*channels[0][i] + 0.587*channels[1][i] + 0.114*channels[2][i]
+		}
+	}
+
+	// 完全已知的块才能作为来源
+	source := make([]bool, w*h)
+	for y := r; y < h-r; y++ {
+		for x := r; x < w-r; x++ {
+			ok := true
+			for dy := -r; dy <= r && ok; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					if unknown[(y+dy)*w+x+dx] {
+						ok = false
+						break
+					}
+				}
+			}
+			source[y*w+x] = ok
+		}
+	}
+
+	isFront := func(x, y int) bool {
+		if filled[y*w+x] {
+			return false
+		}
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			nx, ny := x+d[0], y+d[1]
+			if nx >= 0 && ny >= 0 && nx < w && ny < h && filled[ny*w+nx] {
+				return true
+			}
+		}
+		return false
+	}
+	isFilled := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= w || y >= h || filled[y*w+x] {
+			return 1
+		}
+		return 0
+	}
+
+	for {
+		// 选优先级最高的前沿像素
+		best, bestP, bestC := -1, -1.0, 0.0
+		for y := minY; y <= maxY; y++ {
+			for x := minX; x <= maxX; x++ {
+				if !isFront(x, y) {
+					continue
+				}
+				var c float64
+				var n int
+				for dy := -r; dy <= r; dy++ {
+					for dx := -r; dx <= r; dx++ {
+						nx, ny := x+dx, y+dy
+						if nx >= 0 && ny >= 0 && nx < w && ny < h {
+							c += conf[ny*w+nx]
+							n++
+						}
+					}
+				}
+				c /= float64(n)
+				// 前沿法向(已填充区域指示函数的梯度)与等照度线(梯度旋转 90 度)的点积
+				nx, ny := isFilled(x+1, y)-isFilled(x-1, y), isFilled(x, y+1)-isFilled(x, y-1)
+				var ix, iy float64
+				for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					ax, ay := x+d[0], y+d[1]
+					bx, by := ax+d[0], ay+d[1]
+					if bx < 0 || by < 0 || bx >= w || by >= h || !filled[ay*w+ax] || !filled[by*w+bx] {
+						continue
+					}
+					g := math.Abs(luma[ay*w+ax] - luma[by*w+bx])
+					if d[0] != 0 {
+						iy += g
+					} else {
+						ix += g
+					}
+				}
+				data := math.Abs(ix*nx+iy*ny)/255 + 0.001
+				if pr := c * data; pr > bestP {
+					best, bestP, bestC = y*w+x, pr, c
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+		tx, ty := best%w, best/w
+
+		// 在附近搜索差异最小的来源块
+		sx0, sx1 := maxInt(r, tx-margin), minInt(w-r-1, tx+margin)
+		sy0, sy1 := maxInt(r, ty-margin), minInt(h-r-1, ty+margin)
+		src, srcD := -1, math.Inf(1)
+		for sy := sy0; sy <= sy1; sy++ {
+			for sx := sx0; sx <= sx1; sx++ {
+				if !source[sy*w+sx] {
+					continue
+				}
+				var d float64
+				for dy := -r; dy <= r && d < srcD; dy++ {
+					for dx := -r; dx <= r; dx++ {
+						x, y := tx+dx, ty+dy
+						if x < 0 || y < 0 || x >= w || y >= h || !filled[y*w+x] {
+							continue
+						}
+						s := (sy+dy)*w + sx + dx
+						for _, ch := range channels {
+							v := ch[y*w+x] - ch[s]
+							d += v * v
+						}
+					}
+				}
+				if d < srcD {
+					src, srcD = sy*w+sx, d
+				}
+			}
+		}
+		if src < 0 {
+			// 图片太小找不到完整的来源块, 剩余部分用 Telea 方法填充
+			rest := make([]bool, w*h)
+			for i := range rest {
+				rest[i] = !filled[i]
+			}
+			inpaintTelea(channels, rest, w, h, r+1)
+			return
+		}
+		sx, sy := src%w, src/w
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				x, y := tx+dx, ty+dy
+				if x < 0 || y < 0 || x >= w || y >= h || filled[y*w+x] {
+					continue
+				}
+				s := (sy+dy)*w + sx + dx
+				for _, ch := range channels {
+					ch[y*w+x] = ch[s]
+				}
+				filled[y*w+x] = true
+				conf[y*w+x] = bestC
+			}
+		}
+	}
+}
+
+// minInt 两个整数中较小的
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt 两个整数中较大的
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,115 @@
+package myimage
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+/*
+积分图: 预先求前缀和, 之后任意矩形的和、均值、方差都是 O(1) 查询
+用于盒式模糊、自适应阈值、局部均值/方差、Haar 特征等
+*/
+
+// Integral 积分图和平方积分图, 每个通道一张 (Width+1)x(Height+1) 的表, 第一行和第一列为 0
+type Integral struct {
+	Width  int
+	Height int
+	Sum    [][]float64 // 像素值的前缀和
+	SqSum  [][]float64 // 像素值平方的前缀和
+}
+
+// NewIntegral 单通道平面的积分图
+func NewIntegral(pl *Plane) *Integral {
+	return &Integral{
+		Width:  pl.Width,
+		Height: pl.Height,
+		Sum:    [][]float64{integralOf(pl.Data, pl.Width, pl.Height, false)},
+		SqSum:  [][]float64{integralOf(pl.Data, pl.Width, pl.Height, true)},
+	}
+}
+
+// Integral 图片各通道的积分图: 灰度图 1 个通道, 其余为 R、G、B 3 个通道, 取值范围 0~255
+func (p *Picture) Integral() (*Integral, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	planes, _, w, h := colorPlanes(p.Img)
+	in := &Integral{Width: w, Height: h}
+	for _, data := range planes {
+		in.Sum = append(in.Sum, integralOf(data, w, h, false))
+		in.SqSum = append(in.SqSum, integralOf(data, w, h, true))
+	}
+	return in, nil
+}
+
+// Channels 通道数
+func (in *Integral) Channels() int {
+	return len(in.Sum)
+}
+
+// clip 把矩形裁剪到图片范围内
+func (in *Integral) clip(r image.Rectangle) image.Rectangle {
+	return r.Intersect(image.Rect(0, 0, in.Width, in.Height))
+}
+
+// RectSum 第 c 个通道在矩形 r 内的像素值之和, r 超出图片的部分被忽略
+func (in *Integral) RectSum(c int, r image.Rectangle) float64 {
+	r = in.clip(r)
+	if r.Empty() {
+		return 0
+	}
+	return rectSumOf(in.Sum[c], in.Width, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+}
+
+// Mean 第 c 个通道在矩形 r 内的均值, 只统计图片内的像素; 矩形为空时为 0
+func (in *Integral) Mean(c int, r image.Rectangle) float64 {
+	r = in.clip(r)
+	if r.Empty() {
+		return 0
+	}
+	return rectSumOf(in.Sum[c], in.Width, r.Min.X, r.Min.Y, r.Dx(), r.Dy()) / float64(r.Dx()*r.Dy())
+}
+
+// Variance 第 c 个通道在矩形 r 内的方差(总体方差), 只统计图片内的像素
+func (in *Integral) Variance(c int, r image.Rectangle) float64 {
+	r = in.clip(r)
+	if r.Empty() {
+		return 0
+	}
+	n := float64(r.Dx() * r.Dy())
+	m := rectSumOf(in.Sum[c], in.Width, r.Min.X, r.Min.Y, r.Dx(), r.Dy()) / n
+	v := rectSumOf(in.SqSum[c], in.Width, r.Min.X, r.Min.Y, r.Dx(), r.Dy())/n - m*m
+	// 浮点误差可能使结果略小于 0
+	return math.Max(v, 0)
+}
+
+// boxFilter 盒式均值滤波, 窗口 (2r+1)x(2r+1), 边界处只对图内像素取平均; 耗时与 r 无关
+func boxFilter(data []float64, w, h, r int) []float64 {
+	sum := integralOf(data, w, h, false)
+	out := make([]float64, w*h)
+	parallelRows(h, func(y0, y1 int) {
+		for i := y0; i < y1; i++ {
+			top, bottom := maxInt(i-r, 0), minInt(i+r+1, h)
+			for j := 0; j < w; j++ {
+				left, right := maxInt(j-r, 0), minInt(j+r+1, w)
+				s := rectSumOf(sum, w, left, top, right-left, bottom-top)
+				out[i*w+j] = s / float64((right-left)*(bottom-top))
+			}
+		}
+	})
+	return out
+}
+
+// BoxBlur 盒式模糊(均值滤波), 窗口边长 2·radius+1; 基于积分图, 耗时与半径无关
+func (p *Picture) BoxBlur(p1 *Picture, radius int) (err error) {
+	if radius < 0 {
+		return errors.New("radius must not be negative")
+	}
+	if radius == 0 {
+		return p.Copy(p1)
+	}
+	return p.applyPlanes(p1, false, func(data []float64, w, h int) []float64 {
+		return boxFilter(data, w, h, radius)
+	})
+}
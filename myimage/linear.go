@@ -0,0 +1,240 @@
+package myimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"math"
+	"strconv"
+	"sync"
+)
+
+/*
+线性光: sRGB 等编码的像素值与光强不成正比, 直接对编码值求平均会让高对比度的边缘变暗
+重采样和合成时先按查找表转换到线性光, 处理后再转换回原来的编码
+*/
+
+// LinearOptions 哪些操作在线性光中进行
+type LinearOptions struct {
+	Resample bool // 缩放、旋转、透视变换、金字塔上下采样
+	Blend    bool // 多频段融合等图片合成
+	Filter   bool // Filter 3x3 卷积
+}
+
+// LinearLight 默认重采样和合成在线性光中进行, Filter 保持对编码值卷积
+var LinearLight = LinearOptions{Resample: true, Blend: true}
+
+// ColorSpace 像素值的编码方式(传递函数), 零值为 sRGB
+type ColorSpace struct {
+	Gamma float64 // 0 为 sRGB 曲线, 1 为线性光, 其余为纯幂函数: 编码值 = 光强^(1/Gamma)
+}
+
+var (
+	SpaceSRGB   = ColorSpace{}         // sRGB, 未声明色彩空间的图片都按它处理
+	SpaceLinear = ColorSpace{Gamma: 1} // 线性光, 如科学相机输出的 16 位 png
+)
+
+// IsLinear 是否为线性光
+func (cs ColorSpace) IsLinear() bool {
+	return cs.Gamma == 1
+}
+
+// String 名称
+func (cs ColorSpace) String() string {
+	switch cs.Gamma {
+	case 0:
+		return "sRGB"
+	case 1:
+		return "linear"
+	}
+	return "gamma " + strconv.FormatFloat(cs.Gamma, 'g', -1, 64)
+}
+
+var (
+	srgbOnce   sync.Once
+	srgbDecode []float32 // 16 位 sRGB 编码值 -> 线性光(0~1)
+	srgbEncode []float32 // 16 位线性光 -> sRGB 编码值(0~1)
+)
+
+// srgbTables 初始化查找表
+func srgbTables() {
+	srgbOnce.Do(func() {
+		srgbDecode = make([]float32, 65536)
+		srgbEncode = make([]float32, 65536)
+		for i := range srgbDecode {
+			v := float64(i) / 65535
+			if v <= 0.04045 {
+				srgbDecode[i] = float32(v / 12.92)
+			} else {
+				srgbDecode[i] = float32(math.Pow((v+0.055)/1.055, 2.4))
+			}
+			if v <= 0.0031308 {
+				srgbEncode[i] = float32(v * 12.92)
+			} else {
+				srgbEncode[i] = float32(1.055*math.Pow(v, 1/2.4) - 0.055)
+			}
+		}
+	})
+}
+
+// lookup 在 0~1 上等距采样的查找表中线性插值
+func lookup(t []float32, v float64) float64 {
+	if v <= 0 {
+		return float64(t[0])
+	}
+	f := v * 65535
+	i := int(f)
+	if i >= 65535 {
+		return float64(t[65535])
+	}
+	a := float64(t[i])
+	return a + (float64(t[i+1])-a)*(f-float64(i))
+}
+
+// toLinear 编码值(0~1)转为线性光
+func (cs ColorSpace) toLinear(v float64) float64 {
+	switch {
+	case cs.Gamma == 1:
+		return v
+	case cs.Gamma > 0:
+		return math.Pow(math.Max(v, 0), cs.Gamma)
+	case v > 1:
+		// 浮点图片超出 1 的部分按公式延伸, 不截断
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return lookup(srgbDecode, v)
+}
+
+// fromLinear 线性光转为编码值(0~1)
+func (cs ColorSpace) fromLinear(v float64) float64 {
+	switch {
+	case cs.Gamma == 1:
+		return v
+	case cs.Gamma > 0:
+		return math.Pow(math.Max(v, 0), 1/cs.Gamma)
+	case v > 1:
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return lookup(srgbEncode, v)
+}
+
+// convertPlanes 对预乘 alpha 的颜色平面(0~255)逐像素做 fn, fn 作用在去掉预乘后的 0~1 值上, 原地修改
+func convertPlanes(planes [][]float64, alpha []float64, fn func(float64) float64) {
+	srgbTables()
+	for i, a := range alpha {
+		if a <= 0 {
+			continue
+		}
+		for _, pl := range planes {
+			pl[i] = fn(pl[i]/a) * a
+		}
+	}
+}
+
+// linearize 颜色平面从 cs 编码转换到线性光
+func linearize(planes [][]float64, alpha []float64, cs ColorSpace) {
+	if !cs.IsLinear() {
+		convertPlanes(planes, alpha, cs.toLinear)
+	}
+}
+
+// delinearize linearize 的逆过程
+func delinearize(planes [][]float64, alpha []float64, cs ColorSpace) {
+	if !cs.IsLinear() {
+		convertPlanes(planes, alpha, cs.fromLinear)
+	}
+}
+
+// planesFor 取出颜色平面, linear 为 true 时转换到线性光
+func (p *Picture) planesFor(linear bool) (planes [][]float64, alpha []float64, w, h int) {
+	planes, alpha, w, h = colorPlanes(p.Img)
+	if linear {
+		linearize(planes, alpha, p.Space)
+	}
+	return
+}
+
+// imageFor planesFor 的逆过程, 转换回 p 的编码, 保持 p 的精度
+func (p *Picture) imageFor(planes [][]float64, alpha []float64, w, h int, linear bool) image.Image {
+	if linear {
+		delinearize(planes, alpha, p.Space)
+	}
+	return planesToImage(planes, alpha, w, h, imageDepth(p.Img))
+}
+
+// ConvertSpace 把像素值转换为 cs 编码, 如把线性光的 16 位图片转为 sRGB 后再保存为 8 位
+func (p *Picture) ConvertSpace(p1 *Picture, cs ColorSpace) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if cs.Gamma < 0 {
+		return errors.New("gamma must not be negative")
+	}
+	planes, alpha, w, h := p.planesFor(true)
+	delinearize(planes, alpha, cs)
+	p1.Img = planesToImage(planes, alpha, w, h, imageDepth(p.Img))
+	p1.Space = cs
+	return
+}
+
+// pngColorSpace 从 png 的 sRGB / gAMA 块读取编码方式; 有 sRGB 块时为 sRGB,
+// 只有 gAMA 块时按其中的指数(接近 1 时为线性光), 都没有时返回 false
+// iCCP 块中的 ICC 配置文件不做解析, 按 png 规范回退到 gAMA
+func pngColorSpace(data []byte) (ColorSpace, bool) {
+	const sig = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(sig)) {
+		return SpaceSRGB, false
+	}
+	var gamma float64
+	for pos := len(sig); pos+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		body := data[pos+8:]
+		if n < 0 || n > len(body) {
+			break
+		}
+		body = body[:n]
+		switch typ {
+		case "sRGB":
+			return SpaceSRGB, true
+		case "gAMA":
+			if n == 4 {
+				if g := binary.BigEndian.Uint32(body); g > 0 {
+					gamma = 100000 / float64(g)
+				}
+			}
+		case "IDAT", "IEND":
+			// 色彩空间信息必须出现在图像数据之前
+			pos = len(data)
+			continue
+		}
+		pos += 12 + n
+	}
+	switch {
+	case gamma == 0:
+		return SpaceSRGB, false
+	case math.Abs(gamma-1) < 0.01:
+		return SpaceLinear, true
+	}
+	return ColorSpace{Gamma: math.Round(gamma*100) / 100}, true
+}
+
+// withGAMA 在 png 数据的 IHDR 块之后插入 gAMA 块, 记录非 sRGB 的编码方式
+func withGAMA(data []byte, cs ColorSpace) []byte {
+	// 8 字节签名 + IHDR(4 长度 + 4 类型 + 13 数据 + 4 CRC)
+	const end = 8 + 25
+	if len(data) < end {
+		return data
+	}
+	chunk := make([]byte, 16)
+	binary.BigEndian.PutUint32(chunk, 4)
+	copy(chunk[4:], "gAMA")
+	binary.BigEndian.PutUint32(chunk[8:], uint32(math.Round(100000/cs.Gamma)))
+	binary.BigEndian.PutUint32(chunk[12:], crc32.ChecksumIEEE(chunk[4:12]))
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:end]...)
+	out = append(out, chunk...)
+	return append(out, data[end:]...)
+}
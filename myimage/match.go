@@ -0,0 +1,261 @@
+package myimage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+/*
+模板匹配: 在大图中查找小的参考图(图标、标记点等)
+*/
+
+// MatchMethod 模板匹配的得分方式
+type MatchMethod uint8
+
+const (
+	MatchSSD   MatchMethod = iota // 差的平方和, 越小越好
+	MatchSAD                      // 差的绝对值和, 越小越好
+	MatchCCorr                    // 互相关, 越大越好
+	MatchNCC                      // 归一化互相关(去均值), 取值 -1~1, 越大越好
+)
+
+// lowerIsBetter 得分是否越小越好
+func (m MatchMethod) lowerIsBetter() bool {
+	return m == MatchSSD || m == MatchSAD
+}
+
+// ScoreMap 模板匹配得分图, (x, y) 处的得分对应模板左上角放在原图 (x, y) 处
+type ScoreMap struct {
+	Width  int
+	Height int
+	Data   []float64
+	Method MatchMethod
+}
+
+// At 获取 (x, y) 处的得分
+func (s *ScoreMap) At(x, y int) float64 {
+	return s.Data[y*s.Width+x]
+}
+
+// ToPicture 得分图转成灰度图, 最佳位置最亮
+func (s *ScoreMap) ToPicture(p1 *Picture) (err error) {
+	newImg := image.NewGray(image.Rect(0, 0, s.Width, s.Height))
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range s.Data {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	scale := 0.0
+	if max > min {
+		scale = 255 / (max - min)
+	}
+	for i := 0; i < s.Height; i++ {
+		for j := 0; j < s.Width; j++ {
+			v := (s.At(j, i) - min) * scale
+			if s.Method.lowerIsBetter() {
+				v = 255 - v
+			}
+			newImg.SetGray(j, i, color.Gray{Clip(float32(v), 0, 255)})
+		}
+	}
+	p1.Img = newImg
+	return
+}
+
+// Match 一个匹配结果
+type Match struct {
+	Rect  image.Rectangle // 匹配区域(原图坐标)
+	Score float64
+}
+
+// BestMatches 按得分取最佳的 n 个互不重叠的匹配, tw/th 为模板的宽和高; n <= 0 时返回空结果
+func (s *ScoreMap) BestMatches(n, tw, th int) []Match {
+	if n <= 0 {
+		return nil
+	}
+	idx := make([]int, len(s.Data))
+	for i := range idx {
+		idx[i] = i
+	}
+	lower := s.Method.lowerIsBetter()
+	sort.Slice(idx, func(i, j int) bool {
+		if lower {
+			return s.Data[idx[i]] < s.Data[idx[j]]
+		}
+		return s.Data[idx[i]] > s.Data[idx[j]]
+	})
+
+	matches := make([]Match, 0, n)
+	for _, k := range idx {
+		if len(matches) >= n {
+			break
+		}
+		x, y := k%s.Width, k/s.Width
+		r := image.Rect(x, y, x+tw, y+th)
+		overlap := false
+		for _, m := range matches {
+			if m.Rect.Overlaps(r) {
+				overlap = true
+				break
+			}
+		}
+		if !overlap {
+			matches = append(matches, Match{r, s.Data[k]})
+		}
+	}
+	return matches
+}
+
+// MatchTemplate 模板匹配, 返回得分图
+// mask 可以为 nil: 此时如果模板带透明像素, 则使用模板的 alpha 作为掩码; mask 的亮度即为像素权重
+func (p *Picture) MatchTemplate(tpl *Picture, method MatchMethod, mask *Picture) (*ScoreMap, error) {
+	if p.Img == nil || tpl == nil || tpl.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	src, sw, sh := lumaPlane(p.Img)
+	t, tw, th := lumaPlane(tpl.Img)
+	if tw > sw || th > sh {
+		return nil, errors.New("template is larger than image")
+	}
+
+	// 掩码(权重)
+	var m []float64
+	if mask != nil && mask.Img != nil {
+		var mw, mh int
+		m, mw, mh = lumaPlane(mask.Img)
+		if mw != tw || mh != th {
+			return nil, errors.New("mask size must equal template size")
+		}
+		for i := range m {
+			m[i] /= 255
+		}
+	} else {
+		a, _, _ := alphaPlane(tpl.Img)
+		for _, v := range a {
+			if v < 1 {
+				m = a
+				break
+			}
+		}
+	}
+
+	ow, oh := sw-tw+1, sh-th+1
+	score := &ScoreMap{ow, oh, nil, method}
+
+	// 窗口内 Σm·I 与 Σm·I², 没有掩码时用积分图
+	var winSum, winSq []float64
+	windowSums := func() {
+		if m == nil {
+			sum := integralOf(src, sw, sh, false)
+			sq := integralOf(src, sw, sh, true)
+			winSum = make([]float64, ow*oh)
+			winSq = make([]float64, ow*oh)
+			for y := 0; y < oh; y++ {
+				for x := 0; x < ow; x++ {
+					winSum[y*ow+x] = rectSumOf(sum, sw, x, y, tw, th)
+					winSq[y*ow+x] = rectSumOf(sq, sw, x, y, tw, th)
+				}
+			}
+			return
+		}
+		src2 := make([]float64, len(src))
+		for i, v := range src {
+			src2[i] = v * v
+		}
+		winSum = correlate(src, sw, sh, m, tw, th)
+		winSq = correlate(src2, sw, sh, m, tw, th)
+	}
+	weight := func(i int) float64 {
+		if m == nil {
+			return 1
+		}
+		return m[i]
+	}
+
+	switch method {
+	case MatchSSD:
+		// Σm(I-T)² = Σm·I² - 2Σ(m·T)I + Σm·T²
+		windowSums()
+		mt := make([]float64, len(t))
+		var tSq float64
+		for i, v := range t {
+			mt[i] = weight(i) * v
+			tSq += weight(i) * v * v
+		}
+		cross := correlate(src, sw, sh, mt, tw, th)
+		score.Data = make([]float64, ow*oh)
+		for i := range score.Data {
+			score.Data[i] = math.Max(winSq[i]-2*cross[i]+tSq, 0)
+		}
+	case MatchSAD:
+		score.Data = make([]float64, ow*oh)
+		for y := 0; y < oh; y++ {
+			for x := 0; x < ow; x++ {
+				var s float64
+				for j := 0; j < th; j++ {
+					row := src[(y+j)*sw+x:]
+					for i := 0; i < tw; i++ {
+						s += weight(j*tw+i) * math.Abs(row[i]-t[j*tw+i])
+					}
+				}
+				score.Data[y*ow+x] = s
+			}
+		}
+	case MatchCCorr:
+		mt := make([]float64, len(t))
+		for i, v := range t {
+			mt[i] = weight(i) * v
+		}
+		score.Data = correlate(src, sw, sh, mt, tw, th)
+	case MatchNCC:
+		// 模板去均值后, 分子 Σm(T-mT)·I 不再需要减去窗口均值
+		windowSums()
+		var n, tSum float64
+		for i, v := range t {
+			n += weight(i)
+			tSum += weight(i) * v
+		}
+		if n == 0 {
+			return nil, errors.New("mask is empty")
+		}
+		tMean := tSum / n
+		tz := make([]float64, len(t))
+		var tVar float64
+		for i, v := range t {
+			tz[i] = weight(i) * (v - tMean)
+			tVar += weight(i) * (v - tMean) * (v - tMean)
+		}
+		num := correlate(src, sw, sh, tz, tw, th)
+		score.Data = make([]float64, ow*oh)
+		for i := range score.Data {
+			iVar := winSq[i] - winSum[i]*winSum[i]/n
+			den := math.Sqrt(math.Max(iVar, 0) * tVar)
+			if den > 1e-9 {
+				score.Data[i] = num[i] / den
+			}
+		}
+	default:
+		return nil, errors.New("unknown match method")
+	}
+
+	return score, nil
+}
+
+// FindTemplate 查找模板在图中最佳的 n 个互不重叠的位置
+func (p *Picture) FindTemplate(tpl *Picture, method MatchMethod, n int, mask *Picture) ([]Match, error) {
+	score, err := p.MatchTemplate(tpl, method, mask)
+	if err != nil {
+		return nil, err
+	}
+	tw, th := tpl.GetSize()
+	matches := score.BestMatches(n, tw, th)
+	// 转换到原图坐标
+	min := p.Img.Bounds().Min
+	for i := range matches {
+		matches[i].Rect = matches[i].Rect.Add(min)
+	}
+	return matches, nil
+}
@@ -0,0 +1,259 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+)
+
+/*
+拼图: 把多张图片排成网格或瀑布流(masonry), 用于一次查看大量图片的审阅页
+*/
+
+// FitMode 图片放入单元格的方式
+type FitMode uint8
+
+const (
+	FitContain FitMode = iota // 等比缩放到完整放入, 四周留白
+	FitCover                  // 等比缩放到填满单元格, 裁掉多余部分
+	FitStretch                // 拉伸到单元格大小
+	FitNone                   // 保持原尺寸居中, 超出单元格的部分裁掉
+)
+
+// ParseFitMode 按名称解析: contain、cover、stretch、none
+func ParseFitMode(name string) (FitMode, error) {
+	switch strings.ToLower(name) {
+	case "contain":
+		return FitContain, nil
+	case "cover":
+		return FitCover, nil
+	case "stretch":
+		return FitStretch, nil
+	case "none":
+		return FitNone, nil
+	}
+	return FitContain, fmt.Errorf("unknown fit mode %q", name)
+}
+
+// MontageOptions 拼图选项
+type MontageOptions struct {
+	Columns      int         // 列数, 0 时按每页图片数取接近正方形的列数
+	PerPage      int         // 每页最多的图片数, 0 时全部放在一页
+	CellWidth    int         // 单元格宽, 0 时为 200
+	CellHeight   int         // 单元格高, 0 时与宽相同; Masonry 时不使用
+	Spacing      int         // 单元格之间以及四周的间距
+	Background   color.Color // nil 时为白色
+	Fit          FitMode     // Masonry 时不使用
+	Masonry      bool        // 瀑布流: 列宽固定, 高度随图片比例, 依次放入当前最短的一列
+	Captions     []string    // 每张图片下方的标题, 可以为 nil; 过长时截断
+	CaptionColor color.Color // nil 时为黑色
+	CaptionScale int         // 标题字体放大倍数, 0 时为 1
+}
+
+// defaults 填充零值字段
+func (o MontageOptions) defaults() MontageOptions {
+	if o.CellWidth <= 0 {
+		o.CellWidth = 200
+	}
+	if o.CellHeight <= 0 {
+		o.CellHeight = o.CellWidth
+	}
+	if o.Background == nil {
+		o.Background = color.White
+	}
+	if o.CaptionColor == nil {
+		o.CaptionColor = color.Black
+	}
+	if o.CaptionScale < 1 {
+		o.CaptionScale = 1
+	}
+	return o
+}
+
+// captionHeight 标题区域的高度, 没有标题时为 0
+func (o MontageOptions) captionHeight() int {
+	for _, c := range o.Captions {
+		if c != "" {
+			_, h := TextSize("A", o.CaptionScale)
+			return h + 2*o.CaptionScale
+		}
+	}
+	return 0
+}
+
+// Montage 按选项把图片排版到一页或多页上, 每页返回一张图片
+func Montage(pics []*Picture, opts MontageOptions) ([]*Picture, error) {
+	if len(pics) == 0 {
+		return nil, errors.New("no pictures")
+	}
+	if opts.Spacing < 0 {
+		return nil, errors.New("spacing must not be negative")
+	}
+	if opts.Captions != nil && len(opts.Captions) != len(pics) {
+		return nil, errors.New("caption count does not match picture count")
+	}
+	for _, p := range pics {
+		if p == nil || p.Img == nil {
+			return nil, errors.New("image not loaded")
+		}
+	}
+	opts = opts.defaults()
+	perPage := opts.PerPage
+	if perPage <= 0 {
+		perPage = len(pics)
+	}
+
+	var pages []*Picture
+	for start := 0; start < len(pics); start += perPage {
+		end := start + perPage
+		if end > len(pics) {
+			end = len(pics)
+		}
+		page := opts
+		if opts.Captions != nil {
+			page.Captions = opts.Captions[start:end]
+		}
+		if page.Columns <= 0 {
+			page.Columns = int(math.Ceil(math.Sqrt(float64(end - start))))
+		}
+		var img *image.RGBA
+		if opts.Masonry {
+			img = masonryPage(pics[start:end], page)
+		} else {
+			img = gridPage(pics[start:end], page)
+		}
+		pages = append(pages, &Picture{Img: img, Space: SpaceSRGB})
+	}
+	return pages, nil
+}
+
+// gridPage 网格排版一页
+func gridPage(pics []*Picture, o MontageOptions) *image.RGBA {
+	rows := (len(pics) + o.Columns - 1) / o.Columns
+	capH := o.captionHeight()
+	stepX, stepY := o.CellWidth+o.Spacing, o.CellHeight+capH+o.Spacing
+	sheet := image.NewRGBA(image.Rect(0, 0, o.Spacing+o.Columns*stepX, o.Spacing+rows*stepY))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(o.Background), image.Point{}, draw.Src)
+	for k, p := range pics {
+		x, y := o.Spacing+(k%o.Columns)*stepX, o.Spacing+(k/o.Columns)*stepY
+		cell := image.Rect(x, y, x+o.CellWidth, y+o.CellHeight)
+		drawFitted(sheet, cell, p, o.Fit)
+		if o.Captions != nil {
+			drawCaption(sheet, x, y+o.CellHeight, o.CellWidth, o.Captions[k], o)
+		}
+	}
+	return sheet
+}
+
+// masonryPage 瀑布流排版一页
+func masonryPage(pics []*Picture, o MontageOptions) *image.RGBA {
+	capH := o.captionHeight()
+	// 先计算每张图片的位置, 再确定画布高度
+	heights := make([]int, o.Columns)
+	cells := make([]image.Rectangle, len(pics))
+	for k, p := range pics {
+		col := 0
+		for c := range heights {
+			if heights[c] < heights[col] {
+				col = c
+			}
+		}
+		w, h := p.GetSize()
+		ch := int(math.Round(float64(h) * float64(o.CellWidth) / float64(w)))
+		if ch < 1 {
+			ch = 1
+		}
+		x, y := o.Spacing+col*(o.CellWidth+o.Spacing), o.Spacing+heights[col]
+		cells[k] = image.Rect(x, y, x+o.CellWidth, y+ch)
+		heights[col] += ch + capH + o.Spacing
+	}
+	maxH := 0
+	for _, h := range heights {
+		if h > maxH {
+			maxH = h
+		}
+	}
+	sheet := image.NewRGBA(image.Rect(0, 0, o.Spacing+o.Columns*(o.CellWidth+o.Spacing), o.Spacing+maxH))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(o.Background), image.Point{}, draw.Src)
+	for k, p := range pics {
+		drawFitted(sheet, cells[k], p, FitStretch)
+		if o.Captions != nil {
+			drawCaption(sheet, cells[k].Min.X, cells[k].Max.Y, o.CellWidth, o.Captions[k], o)
+		}
+	}
+	return sheet
+}
+
+// drawFitted 按 fit 方式把图片画进 cell, 其他编码的图片先转换为与画布一致的 sRGB
+func drawFitted(dst draw.Image, cell image.Rectangle, p *Picture, fit FitMode) {
+	img := p.Img
+	iw, ih := img.Bounds().Dx(), img.Bounds().Dy()
+	cw, ch := cell.Dx(), cell.Dy()
+	dw, dh := iw, ih
+	switch fit {
+	case FitContain, FitCover:
+		sx, sy := float64(cw)/float64(iw), float64(ch)/float64(ih)
+		s := math.Min(sx, sy)
+		if fit == FitCover {
+			s = math.Max(sx, sy)
+		}
+		dw, dh = int(math.Round(float64(iw)*s)), int(math.Round(float64(ih)*s))
+		if dw < 1 {
+			dw = 1
+		}
+		if dh < 1 {
+			dh = 1
+		}
+	case FitStretch:
+		dw, dh = cw, ch
+	}
+	if dw != iw || dh != ih {
+		img = scaleImage(p, dw, dh)
+	}
+	if p.Space != SpaceSRGB {
+		conv := &Picture{}
+		(&Picture{Img: img, Space: p.Space}).ConvertSpace(conv, SpaceSRGB)
+		img = conv.Img
+	}
+	// 居中放置, 只画出落在单元格内的部分
+	placed := image.Rect(0, 0, dw, dh).Add(image.Pt(cell.Min.X+(cw-dw)/2, cell.Min.Y+(ch-dh)/2))
+	r := placed.Intersect(cell)
+	draw.Draw(dst, r, img, img.Bounds().Min.Add(r.Min.Sub(placed.Min)), draw.Over)
+}
+
+// drawCaption 在 (x, y) 开始、宽 w 的区域内居中写一行标题, 过长时截断并以 "..." 结尾
+func drawCaption(dst draw.Image, x, y, w int, text string, o MontageOptions) {
+	text = strings.Replace(text, "\n", " ", -1)
+	runes := []rune(text)
+	maxChars := (w + o.CaptionScale) / ((glyphWidth + 1) * o.CaptionScale)
+	if len(runes) > maxChars {
+		if maxChars > 3 {
+			runes = append(runes[:maxChars-3], '.', '.', '.')
+		} else {
+			runes = runes[:maxChars]
+		}
+	}
+	text = string(runes)
+	tw, _ := TextSize(text, o.CaptionScale)
+	DrawText(dst, x+(w-tw)/2, y+o.CaptionScale, text, o.CaptionColor, o.CaptionScale)
+}
+
+// scaleImage 缩放图片: 缩小时按面积平均(避免混叠), 放大时双线性插值, 插值是否在线性光中进行由 LinearLight.Resample 决定
+func scaleImage(p *Picture, w, h int) image.Image {
+	iw, ih := p.GetSize()
+	if w > iw || h > ih {
+		out := &Picture{}
+		p.Resize(out, w, h, "bilinear")
+		return out.Img
+	}
+	linear := LinearLight.Resample
+	planes, alpha, _, _ := p.planesFor(linear)
+	for c := range planes {
+		planes[c] = resizePlane(planes[c], iw, ih, w, h)
+	}
+	return p.imageFor(planes, resizePlane(alpha, iw, ih, w, h), w, h, linear)
+}
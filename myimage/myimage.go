@@ -2,14 +2,11 @@ package myimage
 
 import (
 	"bytes"
-	"encoding/base64"
 	"errors"
 	"image"
 	"image/color"
-	"image/jpeg"
 	"io/ioutil"
 	"math"
-	"math/rand"
 	"os"
 	"sort"
 	"strings"
@@ -24,22 +21,22 @@ type Picture struct {
 	ImgPath string
 	File    *os.File
 	Img     image.Image
+	Space   ColorSpace // 像素值的编码方式, 零值为 sRGB; LoadImg 按 png 的 sRGB / gAMA 块设置
 }
 
-// LoadImg 加载图片
+// LoadImg 加载图片(jpeg / png / gif)
 func (p *Picture) LoadImg() (err error) {
-	f, err := os.Open(p.ImgPath)
+	data, err := ioutil.ReadFile(p.ImgPath)
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	// p.File = f
 
-	Img, err := jpeg.Decode(f)
+	Img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return
 	}
 	p.Img = Img
+	p.Space, _ = pngColorSpace(data)
 
 	return
 }
@@ -59,33 +56,26 @@ func (p *Picture) GetSize() (int, int) {
 	return size.X, size.Y
 }
 
-// Save 图片保存
+// Save 图片保存, 按扩展名选择格式(.png / .gif, 其余为 jpeg)
 func (p *Picture) Save(newPath string) (err error) {
 	// 保存图像
 	f, err := os.Create(newPath)
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	jpeg.Encode(f, p.Img, &jpeg.Options{100})
-
-	return
+	if err = p.Encode(f, FormatFromPath(newPath)); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
 }
 
-// Copy 复制图片
+// Copy 复制图片, 16 位和浮点图片保持原有精度
 func (p *Picture) Copy(p1 *Picture) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, a := p.Img.At(j, i).RGBA()
-			// fmt.Println(Img.At(j, i))
-			newImg.SetRGBA(j, i, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
-		}
+	if p.Img == nil {
+		return errors.New("image not loaded")
 	}
-	p1.Img = newImg
-
+	p.setResult(p1, cloneImage(p.Img))
 	return
 }
 
@@ -101,83 +91,70 @@ func Clip(value, min, max float32) uint8 {
 
 // Crop 按指定大小裁剪
 func (p *Picture) Crop(p1 *Picture, r image.Rectangle) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, a := p.Img.At(j, i).RGBA()
-			newImg.SetRGBA(j, i, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
-		}
+	if p.Img == nil {
+		return errors.New("image not loaded")
 	}
+	newImg := cloneImage(p.Img).(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
 
 	// crop
-	p1.Img = newImg.SubImage(r)
+	p.setResult(p1, newImg.SubImage(r))
 
 	return
 }
 
-// ToGray 图片灰度化
+// ToGray 图片灰度化, 保持原图精度
 func (p *Picture) ToGray(p1 *Picture) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewGray(image.Rect(0, 0, w, h))
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, _ := p.Img.At(j, i).RGBA()
-			// newImg.SetGray(j, i, color.Gray{uint8(uint32(0.39*float32(r)+0.5*float32(g)+0.11*float32(b)) >> 8)})
-			newImg.SetGray(j, i, color.Gray{Clip(0.39*float32(r>>8)+0.5*float32(g>>8)+0.11*float32(b>>8), float32(0.0), float32(255.0))})
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	if len(planes) == 3 {
+		y := make([]float64, w*h)
+		for i := range y {
+			y[i] = 0.39*planes[0][i] + 0.5*planes[1][i] + 0.11*planes[2][i]
 		}
+		planes = [][]float64{y}
 	}
-	p1.Img = newImg
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
 
 	return
 }
 
-// ColorReverse 图片像素值反转
+// ColorReverse 图片像素值反转(透明度不变), 保持原图精度
 func (p *Picture) ColorReverse(p1 *Picture) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, a := p.Img.At(j, i).RGBA()
-			newImg.SetRGBA(j, i, color.RGBA{uint8(255 - r>>8), uint8(255 - g>>8), uint8(255 - b>>8), uint8(255 - a>>8)})
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	for _, pl := range planes {
+		for i, v := range pl {
+			// 预乘 alpha 的值反转为 alpha - v
+			pl[i] = alpha[i] - v
 		}
 	}
-	p1.Img = newImg
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
 
 	return
 }
 
-// HorizontalFlip 水平翻转(左右镜像)
+// HorizontalFlip 水平翻转(左右镜像), 保持原图精度
 func (p *Picture) HorizontalFlip(p1 *Picture) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, a := p.Img.At(j, i).RGBA()
-			newImg.SetRGBA(w-1-j, i, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
-		}
+	if p.Img == nil {
+		return errors.New("image not loaded")
 	}
-	p1.Img = newImg
+	p.setResult(p1, flipImage(p.Img, true))
 
 	return
 }
 
-// VerticalFlip 垂直翻转(上下镜像)
+// VerticalFlip 垂直翻转(上下镜像), 保持原图精度
 func (p *Picture) VerticalFlip(p1 *Picture) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, a := p.Img.At(j, i).RGBA()
-			newImg.SetRGBA(j, h-1-i, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
-		}
+	if p.Img == nil {
+		return errors.New("image not loaded")
 	}
-	p1.Img = newImg
+	p.setResult(p1, flipImage(p.Img, false))
 
 	return
 }
@@ -238,83 +215,48 @@ func BilinearInterpolation(img image.Image, w, h int) image.Image {
 	return newImg
 }
 
-// Rotate 旋转
+// Rotate 绕图片中心旋转 angle 度, 画布大小不变, 空出的部分透明
+// 从输出像素反算原图坐标后双线性插值, 插值是否在线性光中进行由 LinearLight.Resample 决定
 func (p *Picture) Rotate(p1 *Picture, angle float64) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
 	angle = angle / 180.0 * math.Pi
 	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	cx, cy := float64(w)/2.0, float64(h)/2.0
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			r, g, b, a := p.Img.At(j, i).RGBA()
-			// newImg.SetRGBA(j, h-1-i, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
-			// 中心点旋转
-			j1 := float64(j) - cx
-			i1 := float64(i) - cy
-			j1, i1 = j1*math.Cos(angle)-i1*math.Sin(angle), j1*math.Sin(angle)+i1*math.Cos(angle)
-			// 回到原中心点
-			j1 += cx
-			i1 += cy
-			// 判断是否越界
-			j2, i2 := int(j1), int(i1)
-			if j2 < 0 || j2 >= w || i2 < 0 || i2 >= h {
-				continue
-			}
-			newImg.SetRGBA(j2, i2, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)})
-		}
-	}
-	// p1.Img = newImg
-	// 使用插值
-	p1.Img = BilinearInterpolation(newImg, w, h)
-
-	return
+	// 以像素中心为原点旋转
+	cx, cy := float64(w-1)/2.0, float64(h-1)/2.0
+	c, sn := math.Cos(angle), math.Sin(angle)
+	m := Homography{c, -sn, cx - c*cx + sn*cy, sn, c, cy - sn*cx - c*cy, 0, 0, 1}
+	return p.WarpPerspective(p1, m, w, h)
 }
 
-// Filter 滤波
+// Filter 3x3 滤波, 边缘一圈像素为 0, 保持原图精度; 8 / 16 位图片的结果截到取值范围内, 浮点图片不截断
+// LinearLight.Filter 为 true 时在线性光中卷积
 func (p *Picture) Filter(p1 *Picture, arr [9]float32) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-
-	img := p.Img
-	// 修改像素值
-	for i := 1; i < h-1; i++ {
-		for j := 1; j < w-1; j++ {
-			c11 := NewU8color(img, j, i)
-			// 距离为1
-			c01 := NewU8color(img, j-1, i)
-			c21 := NewU8color(img, j+1, i)
-			c10 := NewU8color(img, j, i-1)
-			c12 := NewU8color(img, j, i+1)
-			// 距离为2
-			c00 := NewU8color(img, j-1, i-1)
-			c02 := NewU8color(img, j-1, i+1)
-			c20 := NewU8color(img, j+1, i-1)
-			c22 := NewU8color(img, j+1, i+1)
-
-			newR := Clip(float32(c00.Red)*arr[0]+float32(c01.Red)*arr[1]+float32(c02.Red)*arr[2]+
-				float32(c10.Red)*arr[3]+float32(c11.Red)*arr[4]+float32(c12.Red)*arr[5]+
-				float32(c20.Red)*arr[6]+float32(c21.Red)*arr[7]+float32(c22.Red)*arr[8], float32(0.0), float32(255.0))
-
-			newG := Clip(float32(c00.Green)*arr[0]+float32(c01.Green)*arr[1]+float32(c02.Green)*arr[2]+
-				float32(c10.Green)*arr[3]+float32(c11.Green)*arr[4]+float32(c12.Green)*arr[5]+
-				float32(c20.Green)*arr[6]+float32(c21.Green)*arr[7]+float32(c22.Green)*arr[8], float32(0.0), float32(255.0))
-
-			newB := Clip(float32(c00.Blue)*arr[0]+float32(c01.Blue)*arr[1]+float32(c02.Blue)*arr[2]+
-				float32(c10.Blue)*arr[3]+float32(c11.Blue)*arr[4]+float32(c12.Blue)*arr[5]+
-				float32(c20.Blue)*arr[6]+float32(c21.Blue)*arr[7]+float32(c22.Blue)*arr[8], float32(0.0), float32(255.0))
-
-			newA := Clip(float32(c00.Alpha)*arr[0]+float32(c01.Alpha)*arr[1]+float32(c02.Alpha)*arr[2]+
-				float32(c10.Alpha)*arr[3]+float32(c11.Alpha)*arr[4]+float32(c12.Alpha)*arr[5]+
-				float32(c20.Alpha)*arr[6]+float32(c21.Alpha)*arr[7]+float32(c22.Alpha)*arr[8], float32(0.0), float32(255.0))
-
-			newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
-
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	linear := LinearLight.Filter
+	planes, alpha, w, h := p.planesFor(linear)
+	src := append(planes, alpha)
+	dst := make([][]float64, len(src))
+	for c, data := range src {
+		dst[c] = make([]float64, w*h)
+		for i := 1; i < h-1; i++ {
+			for j := 1; j < w-1; j++ {
+				var v float64
+				// arr[3·dx+dy] 对应 (j-1+dx, i-1+dy)
+				for dx := 0; dx < 3; dx++ {
+					for dy := 0; dy < 3; dy++ {
+						v += float64(arr[3*dx+dy]) * data[(i-1+dy)*w+j-1+dx]
+					}
+				}
+				dst[c][i*w+j] = v
+			}
 		}
-
 	}
-
-	p1.Img = newImg
+	n := len(planes)
+	p.setResult(p1, p.imageFor(dst[:n], dst[n], w, h, linear))
 	return
 }
 
@@ -353,303 +295,96 @@ func SortedU8colorSlice(tmp []U8color, size int, center int) color.RGBA {
 	return color.RGBA{newR[center], newG[center], newB[center], newA[center]}
 }
 
-// MedianFilter 中值滤波 默认 3x3 为例
-func (p *Picture) MedianFilter(p1 *Picture, ksize int) (err error) {
-	img := p.Img
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	pad := ksize / 2
-
-	// 修改像素值
-	for i := pad; i < h-pad; i++ {
-		for j := pad; j < w-pad; j++ {
-			tmp := make([]U8color, 0, ksize*ksize)
-			for ky := 0; ky < ksize; ky++ {
-				for kx := 0; kx < ksize; kx++ {
-					// tmp[kx+ky*ksize] = NewU8color(img, j-pad+kx, i-pad+ky)
-					tmp = append(tmp, NewU8color(img, j-pad+kx, i-pad+ky))
-				}
-			}
-			newImg.SetRGBA(j, i, SortedU8colorSlice(tmp, ksize*ksize, pad))
-		}
-
-	}
-
-	p1.Img = newImg
-	return
-}
-
-// Brightness 改变亮度
+// Brightness 改变亮度, arr 为 R、G、B 各自的系数, 保持原图精度; 浮点图片的结果可以超过 1
 func (p *Picture) Brightness(p1 *Picture, arr [3]float32) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-
-	img := p.Img
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			c11 := NewU8color(img, j, i)
-			newR := Clip(float32(c11.Red)*arr[0], 0, 255)
-			newG := Clip(float32(c11.Green)*arr[1], 0, 255)
-			newB := Clip(float32(c11.Blue)*arr[2], 0, 255)
-			newA := c11.Alpha
-
-			newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
-
-		}
+	if p.Img == nil {
+		return errors.New("image not loaded")
 	}
-	p1.Img = newImg
-	return
-}
-
-// SaltNoise 椒盐噪声
-func (p *Picture) SaltNoise(p1 *Picture, snr float32) (err error) {
-	// snr 信噪比
-	w, h := p.GetSize()
-	noiseSize := int(float32(w*h) * (1 - snr))
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-
-	img := p.Img
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			c11 := NewU8color(img, j, i)
-			newImg.SetRGBA(j, i, color.RGBA{c11.Red, c11.Green, c11.Blue, c11.Alpha})
-		}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	// 灰度图在三个系数不同时变成彩色图
+	if len(planes) == 1 && (arr[0] != arr[1] || arr[0] != arr[2]) {
+		planes = [][]float64{planes[0], append([]float64(nil), planes[0]...), append([]float64(nil), planes[0]...)}
 	}
-
-	// 设置噪声
-	for k := 0; k < noiseSize; k++ {
-		// 随机获取 某个点
-		x := rand.Intn(w)
-		y := rand.Intn(h)
-		// 增加噪声
-		newImg.SetRGBA(x, y, color.RGBA{uint8(0), uint8(0), uint8(0), uint8(0)})
-	}
-
-	p1.Img = newImg
-	return
-}
-
-// GaussianNoise 高斯噪声
-func (p *Picture) GaussianNoise(p1 *Picture, mu, sigma float64) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-
-	img := p.Img
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			c11 := NewU8color(img, j, i)
-			u1 := rand.Float64()
-			u2 := rand.Float64()
-			z0 := math.Sqrt(-2.0*math.Log(u1)) * math.Cos(2*math.Pi*u2)
-			// z1 := math.Sqrt(-2.0*math.Log(u1)) * math.Sin(2*math.Pi*u2)
-			noise := (z0*sigma + mu) * 32
-
-			newR := Clip(float32(noise)+float32(c11.Red), 0, 255)
-			newG := Clip(float32(noise)+float32(c11.Green), 0, 255)
-			newB := Clip(float32(noise)+float32(c11.Blue), 0, 255)
-			newA := Clip(float32(noise)+float32(c11.Alpha), 0, 255)
-
-			newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
+	for c, pl := range planes {
+		for i, v := range pl {
+			pl[i] = float64(arr[c]) * v
 		}
 	}
-
-	p1.Img = newImg
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
 	return
 }
 
-// GradientImage 梯度图像
+// GradientImage 梯度图像, mode 为 "x"(水平方向)、"y"(垂直方向), 其余为两个方向之和
+// 负值截为 0, 最后一行 / 一列没有梯度, 为 0; 透明度和精度保持原图
 func (p *Picture) GradientImage(p1 *Picture, mode string) (err error) {
-	w, h := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-
-	img := p.Img
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
-			c11 := NewU8color(img, j, i)
-			if "x" == strings.ToLower(mode) { // 水平方向梯度图
-				if j < w-1 {
-					c21 := NewU8color(img, j+1, i)
-					newR := Clip(float32(c21.Red)-float32(c11.Red), 0, 255)
-					newG := Clip(float32(c21.Green)-float32(c11.Green), 0, 255)
-					newB := Clip(float32(c21.Blue)-float32(c11.Blue), 0, 255)
-					newA := Clip(float32(c21.Alpha)-float32(c11.Alpha), 0, 255)
-					newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
-				}
-				// } else {
-				// 	newImg.SetRGBA(j, i, color.RGBA{c11.Red, c11.Green, c11.Blue, c11.Alpha})
-				// }
-			} else if "y" == strings.ToLower(mode) { // 垂直方向梯度图
-				if i < h-1 {
-					c12 := NewU8color(img, j, i+1)
-					newR := Clip(float32(c12.Red)-float32(c11.Red), 0, 255)
-					newG := Clip(float32(c12.Green)-float32(c11.Green), 0, 255)
-					newB := Clip(float32(c12.Blue)-float32(c11.Blue), 0, 255)
-					newA := Clip(float32(c12.Alpha)-float32(c11.Alpha), 0, 255)
-					newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
-				}
-				// } else {
-				// 	newImg.SetRGBA(j, i, color.RGBA{c11.Red, c11.Green, c11.Blue, c11.Alpha})
-				// }
-			} else { // 垂直方向+ 水平方向
-				if i < h-1 && j < w-1 {
-					c12 := NewU8color(img, j, i+1)
-					c21 := NewU8color(img, j+1, i)
-
-					newR := Clip(float32(c21.Red)+float32(c12.Red)-float32(c11.Red)*2, 0, 255)
-					newG := Clip(float32(c21.Green)+float32(c12.Green)-float32(c11.Green)*2, 0, 255)
-					newB := Clip(float32(c21.Blue)+float32(c12.Blue)-float32(c11.Blue)*2, 0, 255)
-					newA := Clip(float32(c21.Alpha)+float32(c12.Alpha)-float32(c11.Alpha)*2, 0, 255)
-
-					newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	mode = strings.ToLower(mode)
+	planes, alpha, w, h := colorPlanes(p.Img)
+	for c, pl := range planes {
+		out := make([]float64, w*h)
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				k := i*w + j
+				var v float64
+				switch {
+				case mode == "x" && j < w-1:
+					v = pl[k+1] - pl[k]
+				case mode == "y" && i < h-1:
+					v = pl[k+w] - pl[k]
+				case mode != "x" && mode != "y" && i < h-1 && j < w-1:
+					v = pl[k+1] + pl[k+w] - 2*pl[k]
 				}
+				out[k] = math.Max(v, 0)
 			}
-
 		}
+		planes[c] = out
 	}
-
-	p1.Img = newImg
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
 	return
 }
 
-// Resize ... 使用双线性插值
+// Resize ... 使用双线性插值, mode 为 "bilinear" 或 "nearest"
+// LinearLight.Resample 为 true 时双线性插值在线性光中进行, 缩小高对比度的细节时不会变暗
 func (p *Picture) Resize(p1 *Picture, w, h int, mode string) (err error) {
-	imgW, imgH := p.GetSize()
-	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
-	img := p.Img
-	// 修改像素值
-	for i := 0; i < h; i++ {
-		for j := 0; j < w; j++ {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if mode != "bilinear" && mode != "nearest" {
+		return errors.New("mode only nearest or bilinear")
+	}
+	if w < 0 || h < 0 {
+		return errors.New("size must not be negative")
+	}
+	// 最近邻不混合像素值, 不需要转换
+	linear := LinearLight.Resample && mode == "bilinear"
+	planes, alpha, imgW, imgH := p.planesFor(linear)
+	src := append(planes, alpha)
+	dst := make([][]float64, len(src))
+	for c := range dst {
+		dst[c] = make([]float64, w*h)
+	}
+	parallelRows(h, func(y0, y1 int) {
+		for i := y0; i < y1; i++ {
 			// 反算出对应与原图的坐标
-			x := (float64(j)+0.5)*float64(imgW)/float64(w) - 0.5
 			y := (float64(i)+0.5)*float64(imgH)/float64(h) - 0.5
-
-			x1 := int(math.Floor(x)) // 向下取整
-			y1 := int(math.Floor(y)) // 向下取整
-
-			x2 := int(math.Ceil(x)) // # 向上取整
-			y2 := int(math.Ceil(y)) // # 向上取整
-
-			if x1 < 0 {
-				x1 = 0
-			} else if x2 >= imgW {
-				x2 = imgW - 1
-			}
-
-			if y1 < 0 {
-				y1 = 0
-			} else if y2 >= imgH {
-				y2 = imgH - 1
-			}
-
-			if mode == "bilinear" { // 双线性插值
-
-				c11 := NewU8color(img, x1, y1)
-				c12 := NewU8color(img, x1, y2)
-				c21 := NewU8color(img, x2, y1)
-				c22 := NewU8color(img, x2, y2)
-
-				newR := Clip(float32(float64(c11.Red)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c12.Red)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y2)-y))+
-					float64(c21.Red)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c22.Red)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y2)-y))), 0, 255)
-
-				newG := Clip(float32(float64(c11.Green)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c12.Green)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y2)-y))+
-					float64(c21.Green)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c22.Green)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y2)-y))), 0, 255)
-
-				newB := Clip(float32(float64(c11.Blue)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c12.Blue)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y2)-y))+
-					float64(c21.Blue)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c22.Blue)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y2)-y))), 0, 255)
-
-				newA := Clip(float32(float64(c11.Alpha)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c12.Alpha)*(1-math.Abs(float64(x1)-x))*(1-math.Abs(float64(y2)-y))+
-					float64(c21.Alpha)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y1)-y))+
-					float64(c22.Alpha)*(1-math.Abs(float64(x2)-x))*(1-math.Abs(float64(y2)-y))), 0, 255)
-
-				newImg.SetRGBA(j, i, color.RGBA{newR, newG, newB, newA})
-			} else if mode == "nearest" { // 最近邻插值
-				var newX, newY = 0, 0
-				if math.Abs(float64(x1)-x) <= math.Abs(float64(x2)-x) {
-					newX = x1
-				} else {
-					newX = x2
-				}
-
-				if math.Abs(float64(y1)-y) <= math.Abs(float64(y2)-y) {
-					newY = y1
-				} else {
-					newY = y2
+			for j := 0; j < w; j++ {
+				x := (float64(j)+0.5)*float64(imgW)/float64(w) - 0.5
+				for c, data := range src {
+					if mode == "nearest" {
+						// 与两侧距离相等时取左上方的像素
+						xi := minInt(maxInt(int(math.Ceil(x-0.5)), 0), imgW-1)
+						yi := minInt(maxInt(int(math.Ceil(y-0.5)), 0), imgH-1)
+						dst[c][i*w+j] = data[yi*imgW+xi]
+					} else {
+						dst[c][i*w+j] = bilinearAt(data, imgW, imgH, x, y)
+					}
 				}
-
-				c11 := NewU8color(img, newX, newY)
-				newImg.SetRGBA(j, i, color.RGBA{c11.Red, c11.Green, c11.Blue, c11.Alpha})
-			} else {
-				err = errors.New("mode only nearest or bilinear")
-				return
 			}
 		}
-	}
-	p1.Img = newImg
-	return
-}
-
-// ImgToBase64 image.Image转成 base64
-func (p *Picture) ImgToBase64() string {
-	// 开辟一个新的空buff
-	emptyBuff := bytes.NewBuffer(nil)
-	// img写入到buff
-	jpeg.Encode(emptyBuff, p.Img, nil)
-	// 开辟存储空间
-	dist := make([]byte, 50000)
-	// buff转成base64
-	base64.StdEncoding.Encode(dist, emptyBuff.Bytes())
-	// 输出图片base64(type = []byte)
-	// fmt.Println(string(dist))
-	// buffer输出到jpg文件中（不做处理，直接写到文件）
-	// _ = ioutil.WriteFile("./base64pic.txt", dist, 0666)
-
-	return string(dist)
-}
-
-// FileToBase64  图片路径直接转成 base64
-func FileToBase64(imgPath string) string {
-	ff, _ := ioutil.ReadFile(imgPath)       // 读取整个文件
-	bufstore := make([]byte, 5000000)       //数据缓存
-	base64.StdEncoding.Encode(bufstore, ff) // 文件转base64
-	// _ = ioutil.WriteFile("./output2.jpg.txt", bufstore, 0666) //直接写入到文件就ok。
-
-	return string(bufstore)
-}
-
-// Base642File  base64直接转成保存成图片
-func Base642File(datasource, imgPath string) (err error) {
-	// 成图片文件并把文件写入到buffer
-	ddd, _ := base64.StdEncoding.DecodeString(datasource)
-	// buffer输出到jpg文件中（不做处理，直接写到文件）
-	err = ioutil.WriteFile(imgPath, ddd, 0666)
+	})
+	n := len(planes)
+	p.setResult(p1, p.imageFor(dst[:n], dst[n], w, h, linear))
 	return
 }
-
-// Base642buffer  base64直接转成保存成图片
-func Base642buffer(datasource string) *bytes.Buffer {
-	// 成图片文件并把文件写入到buffer
-	ddd, _ := base64.StdEncoding.DecodeString(datasource)
-	// 必须加一个buffer 不然没有read方法就会报错
-	bbb := bytes.NewBuffer(ddd)
-	return bbb
-}
-
-// BufferToImg  base64直接转成保存成图片
-func BufferToImg(bbb *bytes.Buffer) image.Image {
-	m, _, _ := image.Decode(bbb) // 图片文件解码
-	// rgbImg := m.(*image.YCbCr)
-	// subImg := rgbImg.SubImage(image.Rect(0, 0, w, h)).(*image.YCbCr) //图片裁剪x0 y0 x1 y1
-
-	return m
-}
@@ -0,0 +1,116 @@
+// Package myimagetest 视觉回归测试用的黄金图片(golden image)断言
+//
+// 用法:
+//
+//	myimagetest.AssertImagesEqual(t, got, "testdata/button.png", nil)
+//
+// 使用 go test -update-golden 重新生成黄金图片; 比较失败时在黄金图片旁写出
+// 实际结果 xxx.got.png 和差异图 xxx.diff.png
+package myimagetest
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	myImg "day01/minitools/myimage"
+)
+
+var update = flag.Bool("update-golden", false, "update golden image files")
+
+// Options 比较选项
+type Options struct {
+	Diff          myImg.DiffOptions // 差异图选项(通道容差、抗锯齿检测等)
+	MaxDiffPixels int               // 允许变化的最大像素数
+}
+
+// DefaultOptions 默认选项: 每个通道容差 2, 检测抗锯齿
+var DefaultOptions = Options{
+	Diff: myImg.DiffOptions{Tolerance: [4]uint8{2, 2, 2, 2}, AntiAliasing: true},
+}
+
+// sidePath 黄金图片旁的附属文件路径, 如 a.png -> a.diff.png
+func sidePath(goldenPath, suffix string) string {
+	ext := filepath.Ext(goldenPath)
+	return strings.TrimSuffix(goldenPath, ext) + "." + suffix + ".png"
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// AssertImagesEqual 断言 got 与黄金图片 goldenPath(PNG) 一致, opts 为 nil 时使用 DefaultOptions
+// 带 -update-golden 运行时改为写入黄金图片; 返回是否一致
+func AssertImagesEqual(t testing.TB, got *myImg.Picture, goldenPath string, opts *Options) bool {
+	t.Helper()
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	if got == nil || got.Img == nil {
+		t.Errorf("%s: got image is nil", goldenPath)
+		return false
+	}
+
+	if *update {
+		if err := writePNG(goldenPath, got.Img); err != nil {
+			t.Fatalf("%s: update golden: %v", goldenPath, err)
+		}
+		return true
+	}
+
+	gotPath := sidePath(goldenPath, "got")
+	diffPath := sidePath(goldenPath, "diff")
+	golden, err := readPNG(goldenPath)
+	if err != nil {
+		t.Errorf("%s: %v (run with -update-golden to create it)", goldenPath, err)
+		return false
+	}
+	if golden.Bounds().Size() != got.Img.Bounds().Size() {
+		writePNG(gotPath, got.Img)
+		t.Errorf("%s: size %v, want %v; actual image written to %s",
+			goldenPath, got.Img.Bounds().Size(), golden.Bounds().Size(), gotPath)
+		return false
+	}
+
+	diff := &myImg.Picture{}
+	stats, err := got.Diff(diff, &myImg.Picture{ImgPath: goldenPath, Img: golden}, opts.Diff)
+	if err != nil {
+		t.Errorf("%s: %v", goldenPath, err)
+		return false
+	}
+	if stats.Changed > opts.MaxDiffPixels {
+		writePNG(gotPath, got.Img)
+		writePNG(diffPath, diff.Img)
+		t.Errorf("%s: %d pixels differ (%d anti-aliased ignored, %d allowed); diff written to %s",
+			goldenPath, stats.Changed, stats.AntiAliased, opts.MaxDiffPixels, diffPath)
+		return false
+	}
+	// 通过时清理上次失败留下的文件
+	os.Remove(gotPath)
+	os.Remove(diffPath)
+	return true
+}
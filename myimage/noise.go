@@ -0,0 +1,122 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+/*
+噪声生成(数据增强用)
+所有函数都使用调用方传入的随机源, 相同的种子得到相同的结果; alpha 通道保持不变
+*/
+
+// NewNoiseSource 创建指定种子的随机源
+func NewNoiseSource(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// addNoise 对每个颜色通道的每个像素调用 fn(值, 通道) 得到新值
+func (p *Picture) addNoise(p1 *Picture, rng *rand.Rand, fn func(v float64, c int) float64) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if rng == nil {
+		return errors.New("random source is nil")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	for i := 0; i < w*h; i++ {
+		for c := range planes {
+			planes[c][i] = fn(planes[c][i], c)
+		}
+	}
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
+	return
+}
+
+// SaltPepperNoise 椒盐噪声
+// amount 为被污染像素的比例(0~1), saltRatio 为其中白点(盐)的比例, 其余为黑点(椒)
+func (p *Picture) SaltPepperNoise(p1 *Picture, rng *rand.Rand, amount, saltRatio float64) (err error) {
+	if amount < 0 || amount > 1 || saltRatio < 0 || saltRatio > 1 {
+		return errors.New("amount and saltRatio must be in [0, 1]")
+	}
+	// 同一像素的所有通道一起变成黑或白
+	var value float64
+	return p.addNoise(p1, rng, func(v float64, c int) float64 {
+		if c == 0 {
+			value = -1
+			if rng.Float64() < amount {
+				value = 0
+				if rng.Float64() < saltRatio {
+					value = 255
+				}
+			}
+		}
+		if value < 0 {
+			return v
+		}
+		return value
+	})
+}
+
+// GaussianNoise 加性高斯噪声, mean 和 sigma 的单位为像素值(0~255)
+// perChannel 为 true 时每个通道独立采样(彩色噪声), 否则同一像素各通道使用相同的噪声(亮度噪声)
+func (p *Picture) GaussianNoise(p1 *Picture, rng *rand.Rand, mean, sigma float64, perChannel bool) (err error) {
+	if sigma < 0 {
+		return errors.New("sigma must not be negative")
+	}
+	var noise float64
+	return p.addNoise(p1, rng, func(v float64, c int) float64 {
+		if c == 0 || perChannel {
+			noise = rng.NormFloat64()*sigma + mean
+		}
+		return v + noise
+	})
+}
+
+// poisson 泊松分布采样, lambda 较大时用正态近似
+func poisson(rng *rand.Rand, lambda float64) float64 {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > 30 {
+		return math.Max(0, math.Round(lambda+math.Sqrt(lambda)*rng.NormFloat64()))
+	}
+	l := math.Exp(-lambda)
+	k, prod := 0.0, rng.Float64()
+	for prod > l {
+		k++
+		prod *= rng.Float64()
+	}
+	return k
+}
+
+// PoissonNoise 泊松(散粒)噪声, peak 为像素值 255 对应的光子数, 越小噪声越大
+func (p *Picture) PoissonNoise(p1 *Picture, rng *rand.Rand, peak float64) (err error) {
+	if peak <= 0 {
+		return errors.New("peak must be positive")
+	}
+	return p.addNoise(p1, rng, func(v float64, c int) float64 {
+		return poisson(rng, v/255*peak) / peak * 255
+	})
+}
+
+// SpeckleNoise 乘性(斑点)噪声: v + v·n, n ~ N(0, sigma²)
+func (p *Picture) SpeckleNoise(p1 *Picture, rng *rand.Rand, sigma float64) (err error) {
+	if sigma < 0 {
+		return errors.New("sigma must not be negative")
+	}
+	return p.addNoise(p1, rng, func(v float64, c int) float64 {
+		return v + v*rng.NormFloat64()*sigma
+	})
+}
+
+// UniformNoise 加性均匀噪声, 取值范围 [low, high)(像素值)
+func (p *Picture) UniformNoise(p1 *Picture, rng *rand.Rand, low, high float64) (err error) {
+	if high < low {
+		return errors.New("high must not be less than low")
+	}
+	return p.addNoise(p1, rng, func(v float64, c int) float64 {
+		return v + low + rng.Float64()*(high-low)
+	})
+}
@@ -0,0 +1,248 @@
+package myimage
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+NumPy .npy / .npz 读写, 便于和 Python 工具交换数组
+写出统一为 little-endian float32('<f4'); 读入支持 '<f4'、'<f8'、'|u1'、'<i4'、'<i8', 都转换成 float32
+*/
+
+var npyMagic = []byte("\x93NUMPY")
+
+// WriteNPY 写出 .npy (格式版本 1.0)
+func WriteNPY(w io.Writer, t *Tensor) error {
+	if len(t.Data) != t.Len() {
+		return errors.New("tensor data does not match its shape")
+	}
+	dims := make([]string, len(t.Shape))
+	for i, s := range t.Shape {
+		dims[i] = strconv.Itoa(s)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		shape += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }", shape)
+	// 魔数(6) + 版本(2) + 长度(2) + 头部 以换行结束并按 64 字节对齐
+	total := len(npyMagic) + 4 + len(header) + 1
+	header += strings.Repeat(" ", (64-total%64)%64) + "\n"
+
+	buf := bytes.NewBuffer(nil)
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	data := make([]byte, 4*len(t.Data))
+	for i, v := range t.Data {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// ReadNPY 读取 .npy
+func ReadNPY(r io.Reader) (*Tensor, error) {
+	pre := make([]byte, 8)
+	if _, err := io.ReadFull(r, pre); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pre[:6], npyMagic) {
+		return nil, errors.New("not a npy file")
+	}
+	var hlen int
+	switch pre[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		hlen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		hlen = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", pre[6])
+	}
+	hb := make([]byte, hlen)
+	if _, err := io.ReadFull(r, hb); err != nil {
+		return nil, err
+	}
+	header := string(hb)
+
+	m := npyDescr.FindStringSubmatch(header)
+	if m == nil {
+		return nil, errors.New("npy header has no descr")
+	}
+	descr := m[1]
+	if m := npyFortran.FindStringSubmatch(header); m != nil && m[1] == "True" {
+		return nil, errors.New("fortran order npy is not supported")
+	}
+	m = npyShape.FindStringSubmatch(header)
+	if m == nil {
+		return nil, errors.New("npy header has no shape")
+	}
+	shape := []int{}
+	for _, s := range strings.Split(m[1], ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("bad npy shape %q", m[1])
+		}
+		shape = append(shape, v)
+	}
+
+	var size int
+	var conv func(b []byte) float32
+	switch descr {
+	case "<f4":
+		size, conv = 4, func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+	case "<f8":
+		size, conv = 8, func(b []byte) float32 { return float32(math.Float64frombits(binary.LittleEndian.Uint64(b))) }
+	case "|u1", "<u1":
+		size, conv = 1, func(b []byte) float32 { return float32(b[0]) }
+	case "<i4":
+		size, conv = 4, func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) }
+	case "<i8":
+		size, conv = 8, func(b []byte) float32 { return float32(int64(binary.LittleEndian.Uint64(b))) }
+	default:
+		return nil, fmt.Errorf("unsupported npy dtype %q", descr)
+	}
+	// 元素个数和字节数防溢出; 数据按实际读到的长度分配, 头部声明的形状过大时不会预先分配
+	n := 1
+	for _, v := range shape {
+		if v != 0 && n > math.MaxInt32/size/v {
+			return nil, fmt.Errorf("npy shape %q is too large", m[1])
+		}
+		n *= v
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(n*size)))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < n*size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	t := NewTensor(shape...)
+	for i := range t.Data {
+		t.Data[i] = conv(data[i*size:])
+	}
+	return t, nil
+}
+
+// WriteNPZ 写出 .npz(不压缩的 zip, 与 numpy.savez 相同), 每个数组保存为 name.npy
+func WriteNPZ(w io.Writer, arrays map[string]*Tensor) error {
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err = WriteNPY(f, arrays[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ReadNPZ 读取 .npz(包括 numpy.savez_compressed 生成的压缩文件)
+func ReadNPZ(r io.ReaderAt, size int64) (map[string]*Tensor, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	arrays := make(map[string]*Tensor)
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".npy") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		t, err := ReadNPY(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		arrays[strings.TrimSuffix(f.Name, ".npy")] = t
+	}
+	return arrays, nil
+}
+
+// SaveNPY 保存为 .npy 文件
+func SaveNPY(path string, t *Tensor) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	if err = WriteNPY(f, t); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// LoadNPY 加载 .npy 文件
+func LoadNPY(path string) (*Tensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNPY(f)
+}
+
+// SaveNPZ 保存为 .npz 文件
+func SaveNPZ(path string, arrays map[string]*Tensor) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	if err = WriteNPZ(f, arrays); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// LoadNPZ 加载 .npz 文件
+func LoadNPZ(path string) (map[string]*Tensor, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadNPZ(bytes.NewReader(data), int64(len(data)))
+}
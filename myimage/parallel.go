@@ -0,0 +1,36 @@
+package myimage
+
+import (
+	"runtime"
+	"sync"
+)
+
+/*
+并行执行: 按行分块, 每个 CPU 一个 goroutine
+*/
+
+// parallelRows 把 [0, h) 的行分成若干块并行调用 fn(y0, y1), 全部完成后返回
+func parallelRows(h int, fn func(y0, y1 int)) {
+	n := runtime.GOMAXPROCS(0)
+	if n > h {
+		n = h
+	}
+	if n <= 1 {
+		fn(0, h)
+		return
+	}
+	chunk := (h + n - 1) / n
+	var wg sync.WaitGroup
+	for y0 := 0; y0 < h; y0 += chunk {
+		y1 := y0 + chunk
+		if y1 > h {
+			y1 = h
+		}
+		wg.Add(1)
+		go func(y0, y1 int) {
+			defer wg.Done()
+			fn(y0, y1)
+		}(y0, y1)
+	}
+	wg.Wait()
+}
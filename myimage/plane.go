@@ -0,0 +1,314 @@
+package myimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+/*
+浮点平面: 把图片的某个通道取出为 []float64, 供内部计算使用
+*/
+
+// lumaPlane 亮度平面(Rec.601 加权), 取值范围 0~255
+func lumaPlane(img image.Image) (data []float64, w, h int) {
+	if f, ok := img.(*FloatImage); ok {
+		planes, _, w, h := floatPlanes(f)
+		if len(planes) == 1 {
+			return planes[0], w, h
+		}
+		data = make([]float64, w*h)
+		for i := range data {
+			data[i] = 0.299*planes[0][i] + 0.587*planes[1][i] + 0.114*planes[2][i]
+		}
+		return data, w, h
+	}
+	b := img.Bounds()
+	w, h = b.Dx(), b.Dy()
+	data = make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			r, g, bl, _ := img.At(b.Min.X+j, b.Min.Y+i).RGBA()
+			data[i*w+j] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+		}
+	}
+	return
+}
+
+// alphaPlane 透明度平面, 取值范围 0~1
+func alphaPlane(img image.Image) (data []float64, w, h int) {
+	b := img.Bounds()
+	w, h = b.Dx(), b.Dy()
+	data = make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			_, _, _, a := img.At(b.Min.X+j, b.Min.Y+i).RGBA()
+			data[i*w+j] = float64(a) / 0xffff
+		}
+	}
+	return
+}
+
+// integralOf 积分图, 大小 (w+1)x(h+1), 第一行和第一列为 0
+func integralOf(data []float64, w, h int, square bool) []float64 {
+	sum := make([]float64, (w+1)*(h+1))
+	for i := 0; i < h; i++ {
+		var row float64
+		for j := 0; j < w; j++ {
+			v := data[i*w+j]
+			if square {
+				v *= v
+			}
+			row += v
+			sum[(i+1)*(w+1)+j+1] = sum[i*(w+1)+j+1] + row
+		}
+	}
+	return sum
+}
+
+// rectSumOf 在积分图上求矩形 [x, x+rw) x [y, y+rh) 的和
+func rectSumOf(sum []float64, w, x, y, rw, rh int) float64 {
+	s := w + 1
+	return sum[(y+rh)*s+x+rw] - sum[y*s+x+rw] - sum[(y+rh)*s+x] + sum[y*s+x]
+}
+
+// bilinearAt 平面上 (x, y) 处的双线性插值, 坐标先裁剪到图像范围内
+func bilinearAt(data []float64, w, h int, x, y float64) float64 {
+	x = math.Max(0, math.Min(x, float64(w-1)))
+	y = math.Max(0, math.Min(y, float64(h-1)))
+	x0, y0 := int(x), int(y)
+	x1, y1 := minInt(x0+1, w-1), minInt(y0+1, h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	top := data[y0*w+x0]*(1-fx) + data[y0*w+x1]*fx
+	bottom := data[y1*w+x0]*(1-fx) + data[y1*w+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// resizePlane 区域平均缩放平面(缩小时每个输出像素取覆盖区域的均值)
+func resizePlane(data []float64, sw, sh, dw, dh int) []float64 {
+	out := make([]float64, dw*dh)
+	sx, sy := float64(sw)/float64(dw), float64(sh)/float64(dh)
+	for i := 0; i < dh; i++ {
+		y0, y1 := float64(i)*sy, float64(i+1)*sy
+		for j := 0; j < dw; j++ {
+			x0, x1 := float64(j)*sx, float64(j+1)*sx
+			var sum, area float64
+			for y := int(y0); y < sh && float64(y) < y1; y++ {
+				fy := math.Min(y1, float64(y+1)) - math.Max(y0, float64(y))
+				for x := int(x0); x < sw && float64(x) < x1; x++ {
+					fx := math.Min(x1, float64(x+1)) - math.Max(x0, float64(x))
+					sum += data[y*sw+x] * fx * fy
+					area += fx * fy
+				}
+			}
+			if area > 0 {
+				out[i*dw+j] = sum / area
+			}
+		}
+	}
+	return out
+}
+
+// rgbPlanes 红、绿、蓝三个平面, 取值范围 0~255
+func rgbPlanes(img image.Image) (r, g, bl []float64, w, h int) {
+	b := img.Bounds()
+	w, h = b.Dx(), b.Dy()
+	r = make([]float64, w*h)
+	g = make([]float64, w*h)
+	bl = make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			cr, cg, cb, _ := img.At(b.Min.X+j, b.Min.Y+i).RGBA()
+			r[i*w+j] = float64(cr) / 257
+			g[i*w+j] = float64(cg) / 257
+			bl[i*w+j] = float64(cb) / 257
+		}
+	}
+	return
+}
+
+// Plane 单通道浮点图, 按行存储
+type Plane struct {
+	Width  int
+	Height int
+	Data   []float64
+}
+
+// At 获取 (x, y) 处的值
+func (pl *Plane) At(x, y int) float64 {
+	return pl.Data[y*pl.Width+x]
+}
+
+// ToPicture 线性映射到灰度图: min 映射为 0, max 映射为 255; min == max 时自动取数据范围
+func (pl *Plane) ToPicture(p1 *Picture, min, max float64) (err error) {
+	if min == max {
+		min, max = math.Inf(1), math.Inf(-1)
+		for _, v := range pl.Data {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+	scale := 0.0
+	if max > min {
+		scale = 255 / (max - min)
+	}
+	newImg := image.NewGray(image.Rect(0, 0, pl.Width, pl.Height))
+	for i := 0; i < pl.Height; i++ {
+		for j := 0; j < pl.Width; j++ {
+			newImg.SetGray(j, i, color.Gray{Clip(float32((pl.At(j, i)-min)*scale+0.5), 0, 255)})
+		}
+	}
+	p1.Img = newImg
+	return
+}
+
+// gaussianKernel 归一化的一维高斯核, radius <= 0 时取 ceil(3σ)
+func gaussianKernel(sigma float64, radius int) []float64 {
+	if radius <= 0 {
+		radius = int(math.Ceil(3 * sigma))
+	}
+	k := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range k {
+		d := float64(i - radius)
+		k[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	return k
+}
+
+// convolveSeparable 用同一个一维核先水平再垂直卷积, 边界复制, 输出大小不变
+func convolveSeparable(data []float64, w, h int, k []float64) []float64 {
+	r := len(k) / 2
+	clamp := func(v, n int) int {
+		if v < 0 {
+			return 0
+		} else if v >= n {
+			return n - 1
+		}
+		return v
+	}
+	tmp := make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		row := data[i*w : (i+1)*w]
+		for j := 0; j < w; j++ {
+			var s float64
+			for t, kv := range k {
+				s += kv * row[clamp(j+t-r, w)]
+			}
+			tmp[i*w+j] = s
+		}
+	}
+	out := make([]float64, w*h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			var s float64
+			for t, kv := range k {
+				s += kv * tmp[clamp(i+t-r, h)*w+j]
+			}
+			out[i*w+j] = s
+		}
+	}
+	return out
+}
+
+// isGrayImage 是否为灰度图
+func isGrayImage(img image.Image) bool {
+	switch m := img.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	case *FloatImage:
+		return m.Channels == 1
+	}
+	return false
+}
+
+// colorPlanes 灰度图返回 1 个亮度平面, 彩色图返回 R、G、B 三个平面; alpha 取值 0~255
+func colorPlanes(img image.Image) (planes [][]float64, alpha []float64, w, h int) {
+	if f, ok := img.(*FloatImage); ok {
+		return floatPlanes(f)
+	}
+	if isGrayImage(img) {
+		var luma []float64
+		luma, w, h = lumaPlane(img)
+		planes = [][]float64{luma}
+	} else {
+		var r, g, bl []float64
+		r, g, bl, w, h = rgbPlanes(img)
+		planes = [][]float64{r, g, bl}
+	}
+	alpha, _, _ = alphaPlane(img)
+	for i := range alpha {
+		alpha[i] *= 255
+	}
+	return
+}
+
+// planesToImage colorPlanes 的逆过程: 1 个平面生成灰度图, 3 个平面生成 RGBA 图, depth 指定精度
+// 8 位和 16 位时裁剪到有效范围并四舍五入; DepthFloat 时不裁剪颜色, 以免连续处理时丢失信息
+func planesToImage(planes [][]float64, alpha []float64, w, h int, depth BitDepth) image.Image {
+	r := image.Rect(0, 0, w, h)
+	switch depth {
+	case DepthFloat:
+		if len(planes) == 1 {
+			newImg := NewFloatImage(r, 1)
+			for i, v := range planes[0] {
+				newImg.Pix[i] = float32(v / 255)
+			}
+			return newImg
+		}
+		newImg := NewFloatImage(r, 4)
+		for i := 0; i < w*h; i++ {
+			newImg.Pix[4*i] = float32(planes[0][i] / 255)
+			newImg.Pix[4*i+1] = float32(planes[1][i] / 255)
+			newImg.Pix[4*i+2] = float32(planes[2][i] / 255)
+			newImg.Pix[4*i+3] = float32(math.Max(0, math.Min(1, alpha[i]/255)))
+		}
+		return newImg
+	case Depth16:
+		// 0~255 映射到 0~65535
+		q16 := func(v, max float64) uint16 {
+			return uint16(math.Max(0, math.Min(max, v*257+0.5)))
+		}
+		if len(planes) == 1 {
+			newImg := image.NewGray16(r)
+			for i, v := range planes[0] {
+				newImg.SetGray16(i%w, i/w, color.Gray16{q16(v, 65535)})
+			}
+			return newImg
+		}
+		newImg := image.NewRGBA64(r)
+		for i := 0; i < w*h; i++ {
+			a := q16(alpha[i], 65535)
+			m := float64(a)
+			newImg.SetRGBA64(i%w, i/w, color.RGBA64{q16(planes[0][i], m), q16(planes[1][i], m), q16(planes[2][i], m), a})
+		}
+		return newImg
+	}
+	if len(planes) == 1 {
+		newImg := image.NewGray(r)
+		for i, v := range planes[0] {
+			newImg.Pix[i] = Clip(float32(v+0.5), 0, 255)
+		}
+		return newImg
+	}
+	newImg := image.NewRGBA(r)
+	for i := 0; i < w*h; i++ {
+		// image.RGBA 是预乘 alpha 的, 颜色值不能超过 alpha
+		a := Clip(float32(alpha[i]+0.5), 0, 255)
+		newImg.Pix[4*i] = Clip(float32(planes[0][i]+0.5), 0, float32(a))
+		newImg.Pix[4*i+1] = Clip(float32(planes[1][i]+0.5), 0, float32(a))
+		newImg.Pix[4*i+2] = Clip(float32(planes[2][i]+0.5), 0, float32(a))
+		newImg.Pix[4*i+3] = a
+	}
+	return newImg
+}
+
+// setResult 把处理结果写入 p1, 并沿用 p 的编码方式(Space)
+func (p *Picture) setResult(p1 *Picture, img image.Image) {
+	p1.Img = img
+	p1.Space = p.Space
+}
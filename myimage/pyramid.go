@@ -0,0 +1,302 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+图像金字塔: 高斯金字塔(pyrDown / pyrUp)、拉普拉斯金字塔分解与重建, 以及基于金字塔的多频段融合
+*/
+
+// pyrDownPlane [1 4 6 4 1]/16 高斯平滑后隔行隔列采样, 输出大小 ((w+1)/2, (h+1)/2)
+func pyrDownPlane(data []float64, w, h int) ([]float64, int, int) {
+	blurred := gaussianBlur5(data, w, h)
+	nw, nh := (w+1)/2, (h+1)/2
+	out := make([]float64, nw*nh)
+	for i := 0; i < nh; i++ {
+		for j := 0; j < nw; j++ {
+			out[i*nw+j] = blurred[2*i*w+2*j]
+		}
+	}
+	return out, nw, nh
+}
+
+// upsample1 一维 2 倍上采样, 等价于插零后用 [1 4 6 4 1]/8 卷积; 源下标越界时复制边界
+// 偶数位置 (s[i-1] + 6s[i] + s[i+1]) / 8, 奇数位置 (s[i] + s[i+1]) / 2
+func upsample1(src []float64, n, stride int, dst []float64, dn, dstride int) {
+	at := func(i int) float64 {
+		if i < 0 {
+			i = 0
+		} else if i >= n {
+			i = n - 1
+		}
+		return src[i*stride]
+	}
+	for k := 0; k < dn; k++ {
+		i := k / 2
+		if k%2 == 0 {
+			dst[k*dstride] = (at(i-1) + 6*at(i) + at(i+1)) / 8
+		} else {
+			dst[k*dstride] = (at(i) + at(i+1)) / 2
+		}
+	}
+}
+
+// pyrUpPlane 2 倍上采样到 dw x dh(dw 为 2w 或 2w-1, 与 pyrDownPlane 的输入大小对应)
+func pyrUpPlane(data []float64, w, h, dw, dh int) []float64 {
+	tmp := make([]float64, dw*h)
+	for i := 0; i < h; i++ {
+		upsample1(data[i*w:], w, 1, tmp[i*dw:], dw, 1)
+	}
+	out := make([]float64, dw*dh)
+	for j := 0; j < dw; j++ {
+		upsample1(tmp[j:], h, dw, out[j:], dh, dw)
+	}
+	return out
+}
+
+// maxPyramidLevels 图片最多能分解的层数(最小一层的宽高不小于 1)
+func maxPyramidLevels(w, h int) int {
+	n := 1
+	for w > 1 && h > 1 {
+		w, h = (w+1)/2, (h+1)/2
+		n++
+	}
+	return n
+}
+
+// PyrDown 高斯平滑后缩小一半, 是否在线性光中进行由 LinearLight.Resample 决定
+func (p *Picture) PyrDown(p1 *Picture) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	linear := LinearLight.Resample
+	planes, alpha, w, h := p.planesFor(linear)
+	var nw, nh int
+	for c := range planes {
+		planes[c], nw, nh = pyrDownPlane(planes[c], w, h)
+	}
+	alpha, nw, nh = pyrDownPlane(alpha, w, h)
+	p.setResult(p1, p.imageFor(planes, alpha, nw, nh, linear))
+	return
+}
+
+// PyrUp 放大一倍并做高斯平滑, 是否在线性光中进行由 LinearLight.Resample 决定
+func (p *Picture) PyrUp(p1 *Picture) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	linear := LinearLight.Resample
+	planes, alpha, w, h := p.planesFor(linear)
+	for c := range planes {
+		planes[c] = pyrUpPlane(planes[c], w, h, 2*w, 2*h)
+	}
+	p.setResult(p1, p.imageFor(planes, pyrUpPlane(alpha, w, h, 2*w, 2*h), 2*w, 2*h, linear))
+	return
+}
+
+// Pyramid 图像金字塔, Levels[k][c] 为第 k 层的第 c 个通道(灰度图 1 个, 彩色图 R、G、B 3 个)
+// 第 0 层为原始分辨率, 之后每层宽高减半
+type Pyramid struct {
+	Levels [][]*Plane
+	// Laplacian 为 true 时是拉普拉斯金字塔: 最后一层是高斯低通残差, 其余各层是与下一层上采样结果的差(带通细节)
+	Laplacian bool
+	alpha     []float64  // 原图 alpha(0~255), 重建时使用
+	depth     BitDepth   // 原图精度, 重建时保持
+	space     ColorSpace // 原图编码方式, 重建时沿用
+	linear    bool       // 是否在线性光中分解, 取自 LinearLight.Resample
+}
+
+// gaussianPyramidOf 各通道的高斯金字塔, levels <= 0 时分解到最小
+func gaussianPyramidOf(planes [][]float64, w, h, levels int) [][]*Plane {
+	if max := maxPyramidLevels(w, h); levels <= 0 || levels > max {
+		levels = max
+	}
+	out := make([][]*Plane, levels)
+	for k := 0; k < levels; k++ {
+		out[k] = make([]*Plane, len(planes))
+		for c := range planes {
+			out[k][c] = &Plane{w, h, planes[c]}
+		}
+		if k == levels-1 {
+			break
+		}
+		var nw, nh int
+		next := make([][]float64, len(planes))
+		for c := range planes {
+			next[c], nw, nh = pyrDownPlane(planes[c], w, h)
+		}
+		planes, w, h = next, nw, nh
+	}
+	return out
+}
+
+// toLaplacian 高斯金字塔原地转换成拉普拉斯金字塔
+func toLaplacian(levels [][]*Plane) {
+	for k := 0; k < len(levels)-1; k++ {
+		for c, pl := range levels[k] {
+			small := levels[k+1][c]
+			up := pyrUpPlane(small.Data, small.Width, small.Height, pl.Width, pl.Height)
+			diff := make([]float64, len(pl.Data))
+			for i, v := range pl.Data {
+				diff[i] = v - up[i]
+			}
+			levels[k][c] = &Plane{pl.Width, pl.Height, diff}
+		}
+	}
+}
+
+// collapseLaplacian 从最小一层开始逐层上采样并加上细节, 返回各通道的原始分辨率平面
+func collapseLaplacian(levels [][]*Plane) [][]float64 {
+	n := len(levels)
+	cur := make([][]float64, len(levels[n-1]))
+	for c, pl := range levels[n-1] {
+		cur[c] = append([]float64(nil), pl.Data...)
+	}
+	for k := n - 2; k >= 0; k-- {
+		for c, pl := range levels[k] {
+			small := levels[k+1][c]
+			up := pyrUpPlane(cur[c], small.Width, small.Height, pl.Width, pl.Height)
+			for i, v := range pl.Data {
+				up[i] += v
+			}
+			cur[c] = up
+		}
+	}
+	return cur
+}
+
+// GaussianPyramid 高斯金字塔, levels 为层数(含原图), <= 0 时分解到最小
+// 与 PyrDown 一样, LinearLight.Resample 为 true 时在线性光中分解, 各层数据为线性光的值
+func (p *Picture) GaussianPyramid(levels int) (*Pyramid, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	linear := LinearLight.Resample
+	planes, alpha, w, h := p.planesFor(linear)
+	return &Pyramid{Levels: gaussianPyramidOf(planes, w, h, levels), alpha: alpha, depth: imageDepth(p.Img), space: p.Space, linear: linear}, nil
+}
+
+// LaplacianPyramid 拉普拉斯金字塔, levels 为层数(含原图), <= 0 时分解到最小
+func (p *Picture) LaplacianPyramid(levels int) (*Pyramid, error) {
+	py, err := p.GaussianPyramid(levels)
+	if err != nil {
+		return nil, err
+	}
+	toLaplacian(py.Levels)
+	py.Laplacian = true
+	return py, nil
+}
+
+// Level 第 k 层转成图片便于查看; 拉普拉斯金字塔的细节层加上 128 偏移显示
+func (py *Pyramid) Level(p1 *Picture, k int) (err error) {
+	if k < 0 || k >= len(py.Levels) {
+		return fmt.Errorf("pyramid level %d out of range", k)
+	}
+	lv := py.Levels[k]
+	w, h := lv[0].Width, lv[0].Height
+	offset := 0.0
+	if py.Laplacian && k < len(py.Levels)-1 {
+		offset = 128
+	}
+	planes := make([][]float64, len(lv))
+	for c, pl := range lv {
+		planes[c] = make([]float64, len(pl.Data))
+		for i, v := range pl.Data {
+			planes[c][i] = v + offset
+		}
+	}
+	alpha := make([]float64, w*h)
+	for i := range alpha {
+		alpha[i] = 255
+	}
+	// 细节层是差值, 不做编码转换
+	if py.linear && offset == 0 {
+		delinearize(planes, alpha, py.space)
+	}
+	p1.Img = planesToImage(planes, alpha, w, h, py.depth)
+	p1.Space = py.space
+	return
+}
+
+// Reconstruct 重建原图: 拉普拉斯金字塔逐层上采样相加, 高斯金字塔直接取第 0 层
+func (py *Pyramid) Reconstruct(p1 *Picture) (err error) {
+	if len(py.Levels) == 0 {
+		return errors.New("empty pyramid")
+	}
+	w, h := py.Levels[0][0].Width, py.Levels[0][0].Height
+	var planes [][]float64
+	if py.Laplacian {
+		planes = collapseLaplacian(py.Levels)
+	} else {
+		for _, pl := range py.Levels[0] {
+			planes = append(planes, append([]float64(nil), pl.Data...))
+		}
+	}
+	alpha := py.alpha
+	if len(alpha) != w*h {
+		alpha = make([]float64, w*h)
+		for i := range alpha {
+			alpha[i] = 255
+		}
+	}
+	if py.linear {
+		delinearize(planes, alpha, py.space)
+	}
+	p1.Img = planesToImage(planes, alpha, w, h, py.depth)
+	p1.Space = py.space
+	return
+}
+
+// BlendMultiBand 多频段融合: 按 mask 把 p 与 other 拼接, mask 白色处取 p, 黑色处取 other
+// 两图的拉普拉斯金字塔按 mask 的高斯金字塔逐层加权, 低频过渡宽、高频过渡窄, 接缝不明显
+// 三张图大小必须相同, levels 为金字塔层数, <= 0 时分解到最小; LinearLight.Blend 为 true 时在线性光中融合
+func (p *Picture) BlendMultiBand(p1 *Picture, other, mask *Picture, levels int) (err error) {
+	if p.Img == nil || other == nil || other.Img == nil || mask == nil || mask.Img == nil {
+		return errors.New("image not loaded")
+	}
+	w, h := p.GetSize()
+	if ow, oh := other.GetSize(); ow != w || oh != h {
+		return errors.New("image sizes differ")
+	}
+	if mw, mh := mask.GetSize(); mw != w || mh != h {
+		return errors.New("mask size differs from image size")
+	}
+	linear := LinearLight.Blend
+	pa, alphaA, _, _ := p.planesFor(linear)
+	pb, alphaB, _, _ := other.planesFor(linear)
+	// 有一张是彩色图时, 灰度图的亮度平面复制成 R、G、B
+	if len(pa) == 1 && len(pb) == 3 {
+		pa = [][]float64{pa[0], pa[0], pa[0]}
+	} else if len(pa) == 3 && len(pb) == 1 {
+		pb = [][]float64{pb[0], pb[0], pb[0]}
+	}
+	m, _, _ := lumaPlane(mask.Img)
+	for i := range m {
+		m[i] /= 255
+	}
+
+	la := gaussianPyramidOf(pa, w, h, levels)
+	lb := gaussianPyramidOf(pb, w, h, len(la))
+	lm := gaussianPyramidOf([][]float64{m}, w, h, len(la))
+	toLaplacian(la)
+	toLaplacian(lb)
+	for k := range la {
+		wt := lm[k][0].Data
+		for c := range la[k] {
+			a, b := la[k][c].Data, lb[k][c].Data
+			mixed := make([]float64, len(a))
+			for i := range a {
+				mixed[i] = wt[i]*a[i] + (1-wt[i])*b[i]
+			}
+			la[k][c].Data = mixed
+		}
+	}
+	alpha := make([]float64, w*h)
+	for i := range alpha {
+		alpha[i] = m[i]*alphaA[i] + (1-m[i])*alphaB[i]
+	}
+	p.setResult(p1, p.imageFor(collapseLaplacian(la), alpha, w, h, linear))
+	return
+}
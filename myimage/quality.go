@@ -0,0 +1,223 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+图像质量指标: MSE, PSNR, SSIM 和多尺度 SSIM
+*/
+
+// Channel 参与计算的通道
+type Channel uint8
+
+const (
+	ChannelRed Channel = iota
+	ChannelGreen
+	ChannelBlue
+	ChannelLuma // 亮度(Rec.601)
+)
+
+// ChannelScores 每个通道及亮度的指标
+type ChannelScores struct {
+	Red   float64
+	Green float64
+	Blue  float64
+	Luma  float64
+}
+
+// Get 取出指定通道的指标
+func (c ChannelScores) Get(ch Channel) float64 {
+	switch ch {
+	case ChannelRed:
+		return c.Red
+	case ChannelGreen:
+		return c.Green
+	case ChannelBlue:
+		return c.Blue
+	default:
+		return c.Luma
+	}
+}
+
+// comparePlanes 取出两张同尺寸图片的 R、G、B、亮度平面
+func comparePlanes(a, b *Picture) (pa, pb [4][]float64, w, h int, err error) {
+	if a == nil || b == nil || a.Img == nil || b.Img == nil {
+		err = errors.New("image not loaded")
+		return
+	}
+	if a.Img.Bounds().Size() != b.Img.Bounds().Size() {
+		err = errors.New("image sizes differ")
+		return
+	}
+	pa[0], pa[1], pa[2], w, h = rgbPlanes(a.Img)
+	pb[0], pb[1], pb[2], _, _ = rgbPlanes(b.Img)
+	pa[3], _, _ = lumaPlane(a.Img)
+	pb[3], _, _ = lumaPlane(b.Img)
+	if w == 0 || h == 0 {
+		err = errors.New("empty image")
+	}
+	return
+}
+
+// MSE 均方误差
+func MSE(a, b *Picture) (ChannelScores, error) {
+	pa, pb, _, _, err := comparePlanes(a, b)
+	if err != nil {
+		return ChannelScores{}, err
+	}
+	var s [4]float64
+	for c := 0; c < 4; c++ {
+		var sum float64
+		for i := range pa[c] {
+			d := pa[c][i] - pb[c][i]
+			sum += d * d
+		}
+		s[c] = sum / float64(len(pa[c]))
+	}
+	return ChannelScores{s[0], s[1], s[2], s[3]}, nil
+}
+
+// PSNR 峰值信噪比(dB), 两张图完全相同时为 +Inf
+func PSNR(a, b *Picture) (ChannelScores, error) {
+	mse, err := MSE(a, b)
+	if err != nil {
+		return mse, err
+	}
+	psnr := func(m float64) float64 {
+		if m == 0 {
+			return math.Inf(1)
+		}
+		return 10 * math.Log10(255*255/m)
+	}
+	return ChannelScores{psnr(mse.Red), psnr(mse.Green), psnr(mse.Blue), psnr(mse.Luma)}, nil
+}
+
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// ssimPlane 计算 SSIM 图(11x11, σ=1.5 的高斯窗口), 返回每个像素的 SSIM 和对比度-结构项 cs
+func ssimPlane(x, y []float64, w, h int) (ssim, cs []float64) {
+	k := gaussianKernel(1.5, 5)
+	xx := make([]float64, w*h)
+	yy := make([]float64, w*h)
+	xy := make([]float64, w*h)
+	for i := range x {
+		xx[i] = x[i] * x[i]
+		yy[i] = y[i] * y[i]
+		xy[i] = x[i] * y[i]
+	}
+	mx := convolveSeparable(x, w, h, k)
+	my := convolveSeparable(y, w, h, k)
+	sxx := convolveSeparable(xx, w, h, k)
+	syy := convolveSeparable(yy, w, h, k)
+	sxy := convolveSeparable(xy, w, h, k)
+
+	ssim = make([]float64, w*h)
+	cs = make([]float64, w*h)
+	for i := range ssim {
+		vx := sxx[i] - mx[i]*mx[i]
+		vy := syy[i] - my[i]*my[i]
+		cov := sxy[i] - mx[i]*my[i]
+		cs[i] = (2*cov + ssimC2) / (vx + vy + ssimC2)
+		ssim[i] = (2*mx[i]*my[i] + ssimC1) / (mx[i]*mx[i] + my[i]*my[i] + ssimC1) * cs[i]
+	}
+	return
+}
+
+// mean 平均值
+func mean(vals []float64) float64 {
+	var s float64
+	for _, v := range vals {
+		s += v
+	}
+	return s / float64(len(vals))
+}
+
+// SSIM 结构相似度, 取值 -1~1, 1 表示完全相同
+func SSIM(a, b *Picture) (ChannelScores, error) {
+	pa, pb, w, h, err := comparePlanes(a, b)
+	if err != nil {
+		return ChannelScores{}, err
+	}
+	var s [4]float64
+	for c := 0; c < 4; c++ {
+		m, _ := ssimPlane(pa[c], pb[c], w, h)
+		s[c] = mean(m)
+	}
+	return ChannelScores{s[0], s[1], s[2], s[3]}, nil
+}
+
+// SSIMMap 指定通道每个像素的 SSIM 图, 可用 Plane.ToPicture 可视化
+func SSIMMap(a, b *Picture, ch Channel) (*Plane, error) {
+	if ch > ChannelLuma {
+		return nil, errors.New("unknown channel")
+	}
+	pa, pb, w, h, err := comparePlanes(a, b)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := ssimPlane(pa[ch], pb[ch], w, h)
+	return &Plane{w, h, m}, nil
+}
+
+// msssimWeights 多尺度 SSIM 各尺度的权重(Wang et al. 2003)
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// downsample2 2x2 均值下采样
+func downsample2(data []float64, w, h int) ([]float64, int, int) {
+	nw, nh := w/2, h/2
+	out := make([]float64, nw*nh)
+	for i := 0; i < nh; i++ {
+		for j := 0; j < nw; j++ {
+			out[i*nw+j] = (data[2*i*w+2*j] + data[2*i*w+2*j+1] + data[(2*i+1)*w+2*j] + data[(2*i+1)*w+2*j+1]) / 4
+		}
+	}
+	return out, nw, nh
+}
+
+// msssimPlane 单通道多尺度 SSIM; 图片太小时减少尺度数并重新归一化权重
+func msssimPlane(x, y []float64, w, h int) float64 {
+	levels := 0
+	for lw, lh := w, h; levels < len(msssimWeights) && lw >= 11 && lh >= 11; lw, lh = lw/2, lh/2 {
+		levels++
+	}
+	if levels == 0 {
+		levels = 1
+	}
+	var wsum float64
+	for _, v := range msssimWeights[:levels] {
+		wsum += v
+	}
+	result := 1.0
+	for l := 0; l < levels; l++ {
+		ssim, cs := ssimPlane(x, y, w, h)
+		weight := msssimWeights[l] / wsum
+		v := mean(cs)
+		if l == levels-1 {
+			v = mean(ssim)
+		}
+		result *= math.Pow(math.Max(v, 0), weight)
+		if l < levels-1 {
+			x, _, _ = downsample2(x, w, h)
+			y, w, h = downsample2(y, w, h)
+		}
+	}
+	return result
+}
+
+// MSSSIM 多尺度结构相似度(5 个尺度), 取值 0~1
+func MSSSIM(a, b *Picture) (ChannelScores, error) {
+	pa, pb, w, h, err := comparePlanes(a, b)
+	if err != nil {
+		return ChannelScores{}, err
+	}
+	var s [4]float64
+	for c := 0; c < 4; c++ {
+		s[c] = msssimPlane(pa[c], pb[c], w, h)
+	}
+	return ChannelScores{s[0], s[1], s[2], s[3]}, nil
+}
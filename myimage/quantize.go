@@ -0,0 +1,502 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+颜色量化: 中位切分、八叉树、k-means 三种方法把图片减少到 N 种颜色, 以及主色调提取
+像素先统计到每通道 5 位的直方图中(最多 32768 个颜色桶), 量化在带权的颜色桶上进行
+*/
+
+// QuantizeMethod 颜色量化方法
+type QuantizeMethod uint8
+
+const (
+	QuantizeMedianCut QuantizeMethod = iota // 中位切分, 按像素数均分颜色空间
+	QuantizeOctree                          // 八叉树, 逐层合并像素最少的分支
+	QuantizeKMeans                          // k-means, 以中位切分结果为初值迭代, 颜色误差最小但最慢
+)
+
+// ParseQuantizeMethod 按名称解析: mediancut、octree、kmeans
+func ParseQuantizeMethod(name string) (QuantizeMethod, error) {
+	switch strings.ToLower(name) {
+	case "mediancut", "median-cut":
+		return QuantizeMedianCut, nil
+	case "octree":
+		return QuantizeOctree, nil
+	case "kmeans", "k-means":
+		return QuantizeKMeans, nil
+	}
+	return QuantizeMedianCut, fmt.Errorf("unknown quantize method %q", name)
+}
+
+// colorBin 直方图中的一个颜色桶: 平均颜色和像素数
+type colorBin struct {
+	c [3]float64
+	n float64
+}
+
+// colorHistogram 统计不透明像素(alpha >= 128)的颜色, 返回颜色桶和是否存在透明像素
+func colorHistogram(img image.Image) (bins []colorBin, transparent bool) {
+	b := img.Bounds()
+	type acc struct{ r, g, b, n float64 }
+	hist := make(map[int]*acc)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				transparent = true
+				continue
+			}
+			key := int(c.R>>3)<<10 | int(c.G>>3)<<5 | int(c.B>>3)
+			a := hist[key]
+			if a == nil {
+				a = &acc{}
+				hist[key] = a
+			}
+			a.r += float64(c.R)
+			a.g += float64(c.G)
+			a.b += float64(c.B)
+			a.n++
+		}
+	}
+	keys := make([]int, 0, len(hist))
+	for k := range hist {
+		keys = append(keys, k)
+	}
+	// 固定顺序, 保证结果可复现
+	sort.Ints(keys)
+	for _, k := range keys {
+		a := hist[k]
+		bins = append(bins, colorBin{[3]float64{a.r / a.n, a.g / a.n, a.b / a.n}, a.n})
+	}
+	return
+}
+
+// cluster 一组颜色桶的加权平均颜色和总像素数
+type cluster struct {
+	c [3]float64
+	n float64
+}
+
+// meanOf 颜色桶的加权平均
+func meanOf(bins []colorBin) cluster {
+	var cl cluster
+	for _, b := range bins {
+		for k := 0; k < 3; k++ {
+			cl.c[k] += b.c[k] * b.n
+		}
+		cl.n += b.n
+	}
+	if cl.n > 0 {
+		for k := 0; k < 3; k++ {
+			cl.c[k] /= cl.n
+		}
+	}
+	return cl
+}
+
+// medianCut 中位切分: 每次选颜色范围最大的盒子, 沿最长的通道在像素数的中位处切开
+func medianCut(bins []colorBin, n int) []cluster {
+	boxes := [][]colorBin{bins}
+	for len(boxes) < n {
+		best, bestCh, bestRange := -1, 0, 0.0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for ch := 0; ch < 3; ch++ {
+				lo, hi := math.Inf(1), math.Inf(-1)
+				for _, b := range box {
+					lo = math.Min(lo, b.c[ch])
+					hi = math.Max(hi, b.c[ch])
+				}
+				if hi-lo > bestRange {
+					best, bestCh, bestRange = i, ch, hi-lo
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool { return box[i].c[bestCh] < box[j].c[bestCh] })
+		var total, acc float64
+		for _, b := range box {
+			total += b.n
+		}
+		cut := 1
+		for i, b := range box[:len(box)-1] {
+			acc += b.n
+			cut = i + 1
+			if acc >= total/2 {
+				break
+			}
+		}
+		boxes[best] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+	out := make([]cluster, len(boxes))
+	for i, box := range boxes {
+		out[i] = meanOf(box)
+	}
+	return out
+}
+
+// octreeNode 八叉树节点
+type octreeNode struct {
+	children [8]*octreeNode
+	sum      [3]float64
+	n        float64
+	leaf     bool
+}
+
+// octreeQuantize 八叉树量化: 把颜色插入 8 层八叉树, 从最深一层开始合并像素最少的节点, 直到叶子数不超过 n
+func octreeQuantize(bins []colorBin, n int) []cluster {
+	root := &octreeNode{}
+	levels := make([][]*octreeNode, 8) // 各层有子节点的节点
+	leaves := 0
+	for _, b := range bins {
+		node := root
+		c := [3]uint8{uint8(b.c[0] + 0.5), uint8(b.c[1] + 0.5), uint8(b.c[2] + 0.5)}
+		for depth := 0; depth < 8; depth++ {
+			shift := 7 - uint(depth)
+			idx := (c[0]>>shift&1)<<2 | (c[1]>>shift&1)<<1 | c[2]>>shift&1
+			child := node.children[idx]
+			if child == nil {
+				child = &octreeNode{leaf: depth == 7}
+				node.children[idx] = child
+				if depth < 7 {
+					levels[depth+1] = append(levels[depth+1], child)
+				} else {
+					leaves++
+				}
+			}
+			node = child
+		}
+		for k := 0; k < 3; k++ {
+			node.sum[k] += b.c[k] * b.n
+		}
+		node.n += b.n
+	}
+	levels[0] = []*octreeNode{root}
+
+	// 子树像素数
+	var weight func(nd *octreeNode) float64
+	weight = func(nd *octreeNode) float64 {
+		if nd.leaf {
+			return nd.n
+		}
+		var s float64
+		for _, ch := range nd.children {
+			if ch != nil {
+				s += weight(ch)
+			}
+		}
+		return s
+	}
+	// 逐层向上合并; 合并会使叶子数少于 n, 或只剩根节点可合并时, 改为两两合并最接近的颜色
+merge:
+	for depth := 7; depth >= 1 && leaves > n; depth-- {
+		nodes := levels[depth]
+		sort.SliceStable(nodes, func(i, j int) bool { return weight(nodes[i]) < weight(nodes[j]) })
+		for _, nd := range nodes {
+			if leaves <= n {
+				break merge
+			}
+			// 把子节点(此时都是叶子)合并到 nd
+			merged := 0
+			for _, ch := range nd.children {
+				if ch != nil {
+					merged++
+				}
+			}
+			if leaves-(merged-1) < n {
+				break merge
+			}
+			for i, ch := range nd.children {
+				if ch == nil {
+					continue
+				}
+				for k := 0; k < 3; k++ {
+					nd.sum[k] += ch.sum[k]
+				}
+				nd.n += ch.n
+				nd.children[i] = nil
+			}
+			if merged > 0 {
+				nd.leaf = true
+				leaves -= merged - 1
+			}
+		}
+	}
+
+	var out []cluster
+	var collect func(nd *octreeNode)
+	collect = func(nd *octreeNode) {
+		if nd.leaf {
+			if nd.n > 0 {
+				out = append(out, cluster{[3]float64{nd.sum[0] / nd.n, nd.sum[1] / nd.n, nd.sum[2] / nd.n}, nd.n})
+			}
+			return
+		}
+		for _, ch := range nd.children {
+			if ch != nil {
+				collect(ch)
+			}
+		}
+	}
+	collect(root)
+	return mergeClosest(out, n)
+}
+
+// mergeClosest 反复合并合并代价(Ward 距离)最小的两类, 直到不超过 n 类
+func mergeClosest(cl []cluster, n int) []cluster {
+	for len(cl) > n {
+		bi, bj, best := 0, 1, math.Inf(1)
+		for i := range cl {
+			for j := i + 1; j < len(cl); j++ {
+				if d := cl[i].n * cl[j].n / (cl[i].n + cl[j].n) * colorDist2(cl[i].c, cl[j].c); d < best {
+					bi, bj, best = i, j, d
+				}
+			}
+		}
+		a, b := cl[bi], cl[bj]
+		sum := a.n + b.n
+		for k := 0; k < 3; k++ {
+			cl[bi].c[k] = (a.c[k]*a.n + b.c[k]*b.n) / sum
+		}
+		cl[bi].n = sum
+		cl = append(cl[:bj], cl[bj+1:]...)
+	}
+	return cl
+}
+
+// colorDist2 颜色的欧氏距离平方
+func colorDist2(a, b [3]float64) float64 {
+	d0, d1, d2 := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return d0*d0 + d1*d1 + d2*d2
+}
+
+// kmeansQuantize 带权 k-means, 以中位切分的结果为初始中心
+func kmeansQuantize(bins []colorBin, n int) []cluster {
+	centers := medianCut(append([]colorBin(nil), bins...), n)
+	assign := make([]int, len(bins))
+	sums := make([]cluster, len(centers))
+	for iter := 0; iter < 30; iter++ {
+		// 分配到最近的中心
+		changed := false
+		for i, b := range bins {
+			best, bestD := 0, math.Inf(1)
+			for k, c := range centers {
+				if d := colorDist2(b.c, c.c); d < bestD {
+					best, bestD = k, d
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		for k := range sums {
+			sums[k] = cluster{}
+		}
+		for i, b := range bins {
+			s := &sums[assign[i]]
+			for k := 0; k < 3; k++ {
+				s.c[k] += b.c[k] * b.n
+			}
+			s.n += b.n
+		}
+		if !changed && iter > 0 {
+			break
+		}
+		// 更新中心; 空簇移到当前误差最大的颜色上
+		for k := range centers {
+			if sums[k].n > 0 {
+				for ch := 0; ch < 3; ch++ {
+					centers[k].c[ch] = sums[k].c[ch] / sums[k].n
+				}
+				continue
+			}
+			worst, worstD := -1, 0.0
+			for i, b := range bins {
+				if d := b.n * colorDist2(b.c, centers[assign[i]].c); d > worstD {
+					worst, worstD = i, d
+				}
+			}
+			if worst >= 0 {
+				centers[k].c = bins[worst].c
+			}
+		}
+	}
+	var out []cluster
+	for k, c := range centers {
+		if sums[k].n > 0 {
+			out = append(out, cluster{c.c, sums[k].n})
+		}
+	}
+	return out
+}
+
+// quantizeBins 用指定方法把颜色桶聚成最多 n 类
+func quantizeBins(bins []colorBin, method QuantizeMethod, n int) ([]cluster, error) {
+	if len(bins) == 0 {
+		return nil, nil
+	}
+	switch method {
+	case QuantizeMedianCut:
+		return medianCut(bins, n), nil
+	case QuantizeOctree:
+		return octreeQuantize(bins, n), nil
+	case QuantizeKMeans:
+		return kmeansQuantize(bins, n), nil
+	}
+	return nil, fmt.Errorf("unknown quantize method %d", method)
+}
+
+// toRGBA 聚类中心转成不透明颜色
+func (c cluster) toRGBA() color.RGBA {
+	return color.RGBA{Clip(float32(c.c[0]+0.5), 0, 255), Clip(float32(c.c[1]+0.5), 0, 255), Clip(float32(c.c[2]+0.5), 0, 255), 255}
+}
+
+// Quantize 把图片减少到最多 n(2~256)种颜色, 生成 *image.Paletted 并返回调色板
+// 每个像素映射到调色板中最接近的颜色(不抖动); 图片有透明像素时调色板最后一项为透明色, 占用一个名额
+func (p *Picture) Quantize(p1 *Picture, method QuantizeMethod, n int) (color.Palette, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	if n < 2 || n > 256 {
+		return nil, errors.New("palette size must be between 2 and 256")
+	}
+	bins, transparent := colorHistogram(p.Img)
+	palette, err := paletteFromBins(bins, transparent, method, n)
+	if err != nil {
+		return nil, err
+	}
+	p.setResult(p1, mapToPalette(p.Img, palette))
+	return palette, nil
+}
+
+// paletteFromBins 用颜色桶生成最多 n 色的调色板, 有透明像素时最后一项为透明色
+func paletteFromBins(bins []colorBin, transparent bool, method QuantizeMethod, n int) (color.Palette, error) {
+	if transparent {
+		n--
+	}
+	clusters, err := quantizeBins(bins, method, n)
+	if err != nil {
+		return nil, err
+	}
+	var palette color.Palette
+	for _, c := range clusters {
+		palette = append(palette, c.toRGBA())
+	}
+	if transparent || len(palette) == 0 {
+		palette = append(palette, color.RGBA{})
+	}
+	return palette, nil
+}
+
+// mapToPalette 每个像素取调色板中最接近的颜色, alpha < 128 的像素取透明色(若有)
+func mapToPalette(img image.Image, palette color.Palette) *image.Paletted {
+	b := img.Bounds()
+	out := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette)
+	transparent := -1
+	for i, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent = i
+		}
+	}
+	cache := make(map[color.NRGBA]uint8)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			if c.A < 128 && transparent >= 0 {
+				out.Pix[y*out.Stride+x] = uint8(transparent)
+				continue
+			}
+			c.A = 255
+			idx, ok := cache[c]
+			if !ok {
+				idx = uint8(nearestColor(palette, float64(c.R), float64(c.G), float64(c.B)))
+				cache[c] = idx
+			}
+			out.Pix[y*out.Stride+x] = idx
+		}
+	}
+	return out
+}
+
+// nearestColor 调色板中与 (r, g, b) 欧氏距离最近的不透明颜色下标
+func nearestColor(palette color.Palette, r, g, b float64) int {
+	best, bestD := 0, math.Inf(1)
+	for i, c := range palette {
+		cr, cg, cb, ca := c.RGBA()
+		if ca == 0 {
+			continue
+		}
+		if d := colorDist2([3]float64{r, g, b}, [3]float64{float64(cr >> 8), float64(cg >> 8), float64(cb >> 8)}); d < bestD {
+			best, bestD = i, d
+		}
+	}
+	return best
+}
+
+// ColorShare 一种颜色及其占比
+type ColorShare struct {
+	Color color.RGBA
+	Share float64 // 0~1, 相对参与统计的像素
+}
+
+// DominantOptions 主色调提取选项
+type DominantOptions struct {
+	Method      QuantizeMethod
+	IgnoreWhite bool  // 忽略接近白色的像素(如白色背景)
+	IgnoreBlack bool  // 忽略接近黑色的像素
+	Tolerance   uint8 // 接近白 / 黑的容差, 各通道与 255 / 0 的差都不超过该值; 0 时为 24
+}
+
+// DominantColors 提取最多 n 种主色调, 按占比从大到小排序; 透明像素不参与统计
+func (p *Picture) DominantColors(n int, opts DominantOptions) ([]ColorShare, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	if n < 1 {
+		return nil, errors.New("color count must be positive")
+	}
+	tol := float64(opts.Tolerance)
+	if tol == 0 {
+		tol = 24
+	}
+	bins, _ := colorHistogram(p.Img)
+	kept := bins[:0]
+	for _, b := range bins {
+		lo := math.Min(b.c[0], math.Min(b.c[1], b.c[2]))
+		hi := math.Max(b.c[0], math.Max(b.c[1], b.c[2]))
+		if opts.IgnoreWhite && lo >= 255-tol || opts.IgnoreBlack && hi <= tol {
+			continue
+		}
+		kept = append(kept, b)
+	}
+	clusters, err := quantizeBins(kept, opts.Method, n)
+	if err != nil {
+		return nil, err
+	}
+	var total float64
+	for _, c := range clusters {
+		total += c.n
+	}
+	shares := make([]ColorShare, len(clusters))
+	for i, c := range clusters {
+		shares[i] = ColorShare{c.toRGBA(), c.n / total}
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].Share > shares[j].Share })
+	return shares, nil
+}
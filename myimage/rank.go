@@ -0,0 +1,281 @@
+package myimage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+/*
+秩滤波: 中值、最小值、最大值和任意百分位滤波
+基于滑动直方图, 不对窗口排序: 小窗口逐个加减进出窗口的像素(Huang), 代价随窗口边长线性增长;
+8 位图片窗口边长达到 perColumnMinKsize 后改用列直方图(Perreault-Hébert), 每个像素的代价与窗口大小无关,
+31x31 以上的大窗口也可以使用; 16 位图片的直方图有 65536 级, 只用 Huang 方法; 边界按复制边缘像素处理
+*/
+
+// perColumnMinKsize 8 位图片窗口边长不小于此值时使用列直方图(Perreault), 由 BenchmarkRankPlane 测得
+const perColumnMinKsize = 19
+
+// rankPlane 对一个通道做秩滤波, bits 为 8 或 16, 窗口边长 2r+1, 取窗口内第 rank 小(从 0 开始)的值
+func rankPlane(src []uint16, w, h, r, rank, bits int) []uint16 {
+	if bits == 8 && 2*r+1 >= perColumnMinKsize {
+		return rankPlaneColumns(src, w, h, r, rank)
+	}
+	return rankPlaneHuang(src, w, h, r, rank, bits)
+}
+
+// rankPlaneHuang 窗口直方图随列滑动时逐个加减进出窗口的像素, 每个像素代价 2(2r+1)
+// 直方图分粗、细两级查找: 8 位为 16x16, 16 位为 256x256
+func rankPlaneHuang(src []uint16, w, h, r, rank, bits int) []uint16 {
+	dst := make([]uint16, w*h)
+	clampY := func(y int) int { return minInt(maxInt(y, 0), h-1) }
+	clampX := func(x int) int { return minInt(maxInt(x, 0), w-1) }
+	bins, shift := 1<<uint(bits), uint(bits/2)
+	parallelRows(h, func(y0, y1 int) {
+		rows := make([]int, 2*r+1)
+		fine := make([]int32, bins)
+		coarse := make([]int32, bins>>shift)
+		for y := y0; y < y1; y++ {
+			for dy := -r; dy <= r; dy++ {
+				rows[dy+r] = clampY(y+dy) * w
+			}
+			// 每行开头重新建立窗口直方图
+			for b := range fine {
+				fine[b] = 0
+			}
+			for b := range coarse {
+				coarse[b] = 0
+			}
+			for dx := -r; dx <= r; dx++ {
+				x := clampX(dx)
+				for _, row := range rows {
+					v := src[row+x]
+					fine[v]++
+					coarse[v>>shift]++
+				}
+			}
+			for x := 0; x < w; x++ {
+				if add, sub := clampX(x+r), clampX(x-r-1); x > 0 && add != sub {
+					for _, row := range rows {
+						v := src[row+add]
+						fine[v]++
+						coarse[v>>shift]++
+						v = src[row+sub]
+						fine[v]--
+						coarse[v>>shift]--
+					}
+				}
+				// 先在粗分桶中定位, 再在桶内定位
+				k := int32(rank)
+				cb := 0
+				for ; cb < len(coarse)-1 && k >= coarse[cb]; cb++ {
+					k -= coarse[cb]
+				}
+				b := cb << shift
+				for last := b + 1<<shift - 1; b < last && k >= fine[b]; b++ {
+					k -= fine[b]
+				}
+				dst[y*w+x] = uint16(b)
+			}
+		}
+	})
+	return dst
+}
+
+// rankPlaneColumns 8 位的常数时间秩滤波(Perreault): 每列保存覆盖上下各 r 行的粗、细两级直方图,
+// 随行下移时每列只加减一个像素; 窗口沿行滑动时只加减整列的 16 个粗分桶,
+// 细分桶只在查找落到该粗分桶时才更新到当前位置, 相邻像素的秩大多落在同一粗分桶, 代价与窗口大小无关
+func rankPlaneColumns(src []uint16, w, h, r, rank int) []uint16 {
+	dst := make([]uint16, w*h)
+	clampY := func(y int) int { return minInt(maxInt(y, 0), h-1) }
+	clampX := func(x int) int { return minInt(maxInt(x, 0), w-1) }
+	parallelRows(h, func(y0, y1 int) {
+		fineCols := make([][256]int32, w)
+		coarseCols := make([][16]int32, w)
+		for x := 0; x < w; x++ {
+			for dy := -r; dy <= r; dy++ {
+				v := src[clampY(y0+dy)*w+x]
+				fineCols[x][v]++
+				coarseCols[x][v>>4]++
+			}
+		}
+		var coarse [16]int32
+		var fine [256]int32
+		// 每个粗分桶的细直方图对应的窗口位置
+		var last [16]int
+		for y := y0; y < y1; y++ {
+			if y > y0 {
+				out, in := clampY(y-r-1)*w, clampY(y+r)*w
+				for x := 0; x < w; x++ {
+					v := src[out+x]
+					fineCols[x][v]--
+					coarseCols[x][v>>4]--
+					v = src[in+x]
+					fineCols[x][v]++
+					coarseCols[x][v>>4]++
+				}
+			}
+			// 每行开头重新建立粗直方图, 细直方图全部作废
+			coarse = [16]int32{}
+			for dx := -r; dx <= r; dx++ {
+				c := &coarseCols[clampX(dx)]
+				for b := range coarse {
+					coarse[b] += c[b]
+				}
+			}
+			for b := range last {
+				last[b] = -2*r - 2
+			}
+			for x := 0; x < w; x++ {
+				if add, sub := clampX(x+r), clampX(x-r-1); x > 0 && add != sub {
+					a, s := &coarseCols[add], &coarseCols[sub]
+					for b := range coarse {
+						coarse[b] += a[b] - s[b]
+					}
+				}
+				k := int32(rank)
+				cb := 0
+				for ; cb < 15 && k >= coarse[cb]; cb++ {
+					k -= coarse[cb]
+				}
+				// 把该粗分桶的细直方图更新到当前位置: 落后太多时重建, 否则逐列补上
+				lo := cb << 4
+				f := fine[lo : lo+16]
+				if 2*(x-last[cb]) > 2*r+1 {
+					for i := range f {
+						f[i] = 0
+					}
+					for dx := -r; dx <= r; dx++ {
+						c := fineCols[clampX(x+dx)][lo : lo+16]
+						for i := range f {
+							f[i] += c[i]
+						}
+					}
+				} else {
+					for t := last[cb] + 1; t <= x; t++ {
+						if add, sub := clampX(t+r), clampX(t-r-1); add != sub {
+							a, s := fineCols[add][lo:lo+16], fineCols[sub][lo:lo+16]
+							for i := range f {
+								f[i] += a[i] - s[i]
+							}
+						}
+					}
+				}
+				last[cb] = x
+				b := 0
+				for ; b < 15 && k >= f[b]; b++ {
+					k -= f[b]
+				}
+				dst[y*w+x] = uint16(lo + b)
+			}
+		}
+	})
+	return dst
+}
+
+// rankFilter 对每个通道(灰度图 1 个, 其余为预乘的 R、G、B、A)做秩滤波
+// 各通道独立取秩, 由于预乘后颜色分量逐像素不超过 alpha, 结果仍满足这一约束
+// 8 位和 16 位图片按原精度处理; 浮点图片没有有限的取值范围, 不支持, 可先转为 Depth16
+func (p *Picture) rankFilter(p1 *Picture, ksize, rank int) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if ksize < 1 || ksize%2 == 0 {
+		return errors.New("ksize must be a positive odd number")
+	}
+	depth := imageDepth(p.Img)
+	if depth == DepthFloat {
+		return errors.New("rank filters do not support float images, convert to Depth16 first")
+	}
+	bits, shift := 8, uint(8)
+	if depth == Depth16 {
+		bits, shift = 16, 0
+	}
+	gray := isGrayImage(p.Img)
+	nc := 4
+	if gray {
+		nc = 1
+	}
+	b := p.Img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := make([][]uint16, nc)
+	for c := range src {
+		src[c] = make([]uint16, w*h)
+	}
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			r, g, bl, a := p.Img.At(b.Min.X+j, b.Min.Y+i).RGBA()
+			v := [4]uint32{r, g, bl, a}
+			for c := range src {
+				src[c][i*w+j] = uint16(v[c] >> shift)
+			}
+		}
+	}
+	out := make([][]uint16, nc)
+	for c := range src {
+		out[c] = rankPlane(src[c], w, h, ksize/2, rank, bits)
+	}
+	rect := image.Rect(0, 0, w, h)
+	switch {
+	case gray && bits == 8:
+		newImg := image.NewGray(rect)
+		for i, v := range out[0] {
+			newImg.Pix[i] = uint8(v)
+		}
+		p.setResult(p1, newImg)
+	case gray:
+		newImg := image.NewGray16(rect)
+		for i, v := range out[0] {
+			newImg.SetGray16(i%w, i/w, color.Gray16{v})
+		}
+		p.setResult(p1, newImg)
+	case bits == 8:
+		newImg := image.NewRGBA(rect)
+		for i := 0; i < w*h; i++ {
+			for c := 0; c < 4; c++ {
+				newImg.Pix[4*i+c] = uint8(out[c][i])
+			}
+		}
+		p.setResult(p1, newImg)
+	default:
+		newImg := image.NewRGBA64(rect)
+		for i := 0; i < w*h; i++ {
+			newImg.SetRGBA64(i%w, i/w, color.RGBA64{out[0][i], out[1][i], out[2][i], out[3][i]})
+		}
+		p.setResult(p1, newImg)
+	}
+	return
+}
+
+// MedianFilter 中值滤波, ksize 为窗口边长(正奇数), 8 位图片大窗口时耗时与窗口大小无关
+func (p *Picture) MedianFilter(p1 *Picture, ksize int) (err error) {
+	return p.rankFilter(p1, ksize, ksize*ksize/2)
+}
+
+// MinFilter 最小值滤波(灰度腐蚀)
+func (p *Picture) MinFilter(p1 *Picture, ksize int) (err error) {
+	return p.rankFilter(p1, ksize, 0)
+}
+
+// MaxFilter 最大值滤波(灰度膨胀)
+func (p *Picture) MaxFilter(p1 *Picture, ksize int) (err error) {
+	return p.rankFilter(p1, ksize, ksize*ksize-1)
+}
+
+// RankFilter 秩滤波: 取窗口内第 rank 小的值, rank 取值 0 ~ ksize·ksize-1
+func (p *Picture) RankFilter(p1 *Picture, ksize, rank int) (err error) {
+	if rank < 0 || rank >= ksize*ksize {
+		return errors.New("rank out of range")
+	}
+	return p.rankFilter(p1, ksize, rank)
+}
+
+// PercentileFilter 百分位滤波, percentile 取值 0~100: 0 为最小值, 50 为中值, 100 为最大值
+func (p *Picture) PercentileFilter(p1 *Picture, ksize int, percentile float64) (err error) {
+	if percentile < 0 || percentile > 100 {
+		return errors.New("percentile must be in [0, 100]")
+	}
+	n := ksize * ksize
+	return p.rankFilter(p1, ksize, int(math.Round(percentile/100*float64(n-1))))
+}
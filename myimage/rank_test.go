@@ -0,0 +1,114 @@
+package myimage
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// bruteRank 对复制边缘后的窗口排序取第 rank 小的值
+func bruteRank(src []uint16, w, h, r, rank int) []uint16 {
+	dst := make([]uint16, w*h)
+	win := make([]int, 0, (2*r+1)*(2*r+1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			win = win[:0]
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					yy := minInt(maxInt(y+dy, 0), h-1)
+					xx := minInt(maxInt(x+dx, 0), w-1)
+					win = append(win, int(src[yy*w+xx]))
+				}
+			}
+			sort.Ints(win)
+			dst[y*w+x] = uint16(win[rank])
+		}
+	}
+	return dst
+}
+
+func randomPlane(rng *rand.Rand, n, bits int) []uint16 {
+	src := make([]uint16, n)
+	for i := range src {
+		src[i] = uint16(rng.Intn(1 << uint(bits)))
+	}
+	return src
+}
+
+func TestRankPlane(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	impls := []struct {
+		name string
+		bits int
+		f    func(src []uint16, w, h, r, rank int) []uint16
+	}{
+		{"huang8", 8, func(src []uint16, w, h, r, rank int) []uint16 { return rankPlaneHuang(src, w, h, r, rank, 8) }},
+		{"huang16", 16, func(src []uint16, w, h, r, rank int) []uint16 { return rankPlaneHuang(src, w, h, r, rank, 16) }},
+		{"columns8", 8, rankPlaneColumns},
+	}
+	// 包括窗口比图片还大的情况
+	for _, size := range [][2]int{{1, 1}, {7, 3}, {23, 17}} {
+		w, h := size[0], size[1]
+		for _, r := range []int{0, 1, 3, 12} {
+			n := (2*r + 1) * (2*r + 1)
+			for _, rank := range []int{0, n / 2, n - 1} {
+				for _, impl := range impls {
+					src := randomPlane(rng, w*h, impl.bits)
+					want := bruteRank(src, w, h, r, rank)
+					got := impl.f(src, w, h, r, rank)
+					for i := range want {
+						if got[i] != want[i] {
+							t.Fatalf("%s %dx%d r=%d rank=%d: pixel (%d, %d) = %d, want %d",
+								impl.name, w, h, r, rank, i%w, i/w, got[i], want[i])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// 中值滤波的边界像素也按复制边缘后的完整窗口计算
+func TestMedianFilterBorders(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	w, h := 19, 13
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	rng.Read(img.Pix)
+	src := make([]uint16, w*h)
+	for i, v := range img.Pix {
+		src[i] = uint16(v)
+	}
+	for _, ksize := range []int{3, 5, 15} {
+		p1 := &Picture{}
+		if err := (&Picture{Img: img}).MedianFilter(p1, ksize); err != nil {
+			t.Fatal(err)
+		}
+		out := p1.Img.(*image.Gray)
+		want := bruteRank(src, w, h, ksize/2, ksize*ksize/2)
+		for i := range want {
+			if uint16(out.Pix[i]) != want[i] {
+				t.Fatalf("ksize %d: pixel (%d, %d) = %d, want %d", ksize, i%w, i/w, out.Pix[i], want[i])
+			}
+		}
+	}
+}
+
+func BenchmarkRankPlane(b *testing.B) {
+	w, h := 1000, 1000
+	src := randomPlane(rand.New(rand.NewSource(3)), w*h, 8)
+	for _, ksize := range []int{3, 7, 15, 19, 31, 63, 127} {
+		r := ksize / 2
+		b.Run(fmt.Sprintf("huang/k%d", ksize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rankPlaneHuang(src, w, h, r, ksize*ksize/2, 8)
+			}
+		})
+		b.Run(fmt.Sprintf("columns/k%d", ksize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rankPlaneColumns(src, w, h, r, ksize*ksize/2)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package myimage
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+)
+
+/*
+接缝裁剪(seam carving): 内容感知缩放, 删除或复制能量最低的缝, 改变宽高比时不压扁主体
+能量为亮度的 Sobel 梯度幅值(|gx| + |gy|), 保护区域加上很大的能量, 待删除区域加上很大的负能量
+*/
+
+const (
+	seamProtectEnergy = 1e6  // 保护区域每个像素附加的能量
+	seamRemoveEnergy  = -1e6 // 待删除区域每个像素附加的能量
+)
+
+// carver 接缝裁剪的工作数据, 只处理竖直方向的缝, 水平方向的缝通过转置处理
+type carver struct {
+	w, h  int
+	pix   [][4]float64 // 预乘 alpha 的 R、G、B、A(0~255), 灰度图的 R、G、B 相同
+	bias  []float64    // 附加能量
+	gray  bool         // 原图是否为灰度图
+	depth BitDepth     // 原图精度, 输出时保持
+}
+
+// newCarver 由图片和可选的保护 / 删除掩码(亮度 >= 128 为有效)创建
+func newCarver(img image.Image, protect, remove *Picture) (*carver, error) {
+	planes, alpha, w, h := colorPlanes(img)
+	c := &carver{w: w, h: h, gray: len(planes) == 1, depth: imageDepth(img)}
+	c.pix = make([][4]float64, w*h)
+	c.bias = make([]float64, w*h)
+	for i := range c.pix {
+		for k := 0; k < 3; k++ {
+			c.pix[i][k] = planes[k%len(planes)][i]
+		}
+		c.pix[i][3] = alpha[i]
+	}
+	for _, m := range []struct {
+		mask   *Picture
+		energy float64
+	}{{protect, seamProtectEnergy}, {remove, seamRemoveEnergy}} {
+		if m.mask == nil {
+			continue
+		}
+		if m.mask.Img == nil {
+			return nil, errors.New("mask not loaded")
+		}
+		if mw, mh := m.mask.GetSize(); mw != c.w || mh != c.h {
+			return nil, errors.New("mask size differs from image size")
+		}
+		luma, _, _ := lumaPlane(m.mask.Img)
+		for i, v := range luma {
+			if v >= 128 {
+				c.bias[i] = m.energy
+			}
+		}
+	}
+	return c, nil
+}
+
+// transpose 行列互换, 用于处理水平方向的缝
+func (c *carver) transpose() {
+	pix := make([][4]float64, len(c.pix))
+	bias := make([]float64, len(c.bias))
+	for i := 0; i < c.h; i++ {
+		for j := 0; j < c.w; j++ {
+			pix[j*c.h+i] = c.pix[i*c.w+j]
+			bias[j*c.h+i] = c.bias[i*c.w+j]
+		}
+	}
+	c.pix, c.bias = pix, bias
+	c.w, c.h = c.h, c.w
+}
+
+// energy 能量图: 亮度 Sobel 梯度幅值(边界复制)加上附加能量
+func (c *carver) energy() []float64 {
+	luma := make([]float64, c.w*c.h)
+	for i, px := range c.pix {
+		luma[i] = 0.299*px[0] + 0.587*px[1] + 0.114*px[2]
+	}
+	pw := c.w + 2
+	gx, gy := sobel(padPlane(luma, c.w, c.h, 1), pw, c.h+2)
+	e := make([]float64, c.w*c.h)
+	for i := 0; i < c.h; i++ {
+		for j := 0; j < c.w; j++ {
+			k := (i+1)*pw + j + 1
+			e[i*c.w+j] = math.Abs(gx[k]) + math.Abs(gy[k]) + c.bias[i*c.w+j]
+		}
+	}
+	return e
+}
+
+// findSeam 动态规划求累积能量最小的竖直缝, 返回每一行的列下标
+func (c *carver) findSeam() []int {
+	w, h := c.w, c.h
+	m := c.energy()
+	for i := 1; i < h; i++ {
+		for j := 0; j < w; j++ {
+			best := m[(i-1)*w+j]
+			if j > 0 && m[(i-1)*w+j-1] < best {
+				best = m[(i-1)*w+j-1]
+			}
+			if j < w-1 && m[(i-1)*w+j+1] < best {
+				best = m[(i-1)*w+j+1]
+			}
+			m[i*w+j] += best
+		}
+	}
+	seam := make([]int, h)
+	for j := 1; j < w; j++ {
+		if m[(h-1)*w+j] < m[(h-1)*w+seam[h-1]] {
+			seam[h-1] = j
+		}
+	}
+	for i := h - 2; i >= 0; i-- {
+		x := seam[i+1]
+		best := x
+		for _, j := range []int{x - 1, x + 1} {
+			if j >= 0 && j < w && m[i*w+j] < m[i*w+best] {
+				best = j
+			}
+		}
+		seam[i] = best
+	}
+	return seam
+}
+
+// removeSeam 删除一条竖直缝, 宽度减 1
+func (c *carver) removeSeam(seam []int) {
+	nw := c.w - 1
+	pix := make([][4]float64, nw*c.h)
+	bias := make([]float64, nw*c.h)
+	for i, x := range seam {
+		copy(pix[i*nw:], c.pix[i*c.w:i*c.w+x])
+		copy(pix[i*nw+x:], c.pix[i*c.w+x+1:(i+1)*c.w])
+		copy(bias[i*nw:], c.bias[i*c.w:i*c.w+x])
+		copy(bias[i*nw+x:], c.bias[i*c.w+x+1:(i+1)*c.w])
+	}
+	c.pix, c.bias, c.w = pix, bias, nw
+}
+
+// reduce 删除 n 条竖直缝
+func (c *carver) reduce(n int) {
+	for k := 0; k < n; k++ {
+		c.removeSeam(c.findSeam())
+	}
+}
+
+// enlarge 插入 n 条竖直缝: 先在副本上找出要删除的 n 条缝, 再在原图的这些位置复制像素(取与右邻的平均)
+// 每批最多插入当前宽度的一半, 避免反复拉伸同一条缝
+func (c *carver) enlarge(n int) {
+	for n > 0 {
+		batch := n
+		if max := c.w / 2; batch > max {
+			batch = max
+		}
+		if batch < 1 {
+			batch = 1
+		}
+		// orig 记录副本中每个像素在原图中的列
+		tmp := &carver{w: c.w, h: c.h, pix: append([][4]float64(nil), c.pix...), bias: append([]float64(nil), c.bias...)}
+		orig := make([]int, c.w*c.h)
+		for i := range orig {
+			orig[i] = i % c.w
+		}
+		inserted := make([][]int, c.h)
+		for k := 0; k < batch && tmp.w > 1; k++ {
+			seam := tmp.findSeam()
+			nw := tmp.w - 1
+			next := make([]int, nw*tmp.h)
+			for i, x := range seam {
+				inserted[i] = append(inserted[i], orig[i*tmp.w+x])
+				copy(next[i*nw:], orig[i*tmp.w:i*tmp.w+x])
+				copy(next[i*nw+x:], orig[i*tmp.w+x+1:(i+1)*tmp.w])
+			}
+			orig = next
+			tmp.removeSeam(seam)
+		}
+		added := len(inserted[0])
+		if added == 0 {
+			return
+		}
+		nw := c.w + added
+		pix := make([][4]float64, nw*c.h)
+		bias := make([]float64, nw*c.h)
+		for i := 0; i < c.h; i++ {
+			xs := inserted[i]
+			sort.Ints(xs)
+			k, o := 0, i*nw
+			for j := 0; j < c.w; j++ {
+				pix[o], bias[o] = c.pix[i*c.w+j], c.bias[i*c.w+j]
+				o++
+				for ; k < len(xs) && xs[k] == j; k++ {
+					r := j + 1
+					if r >= c.w {
+						r = j
+					}
+					a, b := c.pix[i*c.w+j], c.pix[i*c.w+r]
+					for ch := range a {
+						pix[o][ch] = (a[ch] + b[ch]) / 2
+					}
+					bias[o] = c.bias[i*c.w+j]
+					o++
+				}
+			}
+		}
+		c.pix, c.bias, c.w = pix, bias, nw
+		n -= added
+	}
+}
+
+// resizeWidth 把宽调整为 w
+func (c *carver) resizeWidth(w int) {
+	if w < c.w {
+		c.reduce(c.w - w)
+	} else if w > c.w {
+		c.enlarge(w - c.w)
+	}
+}
+
+// hasRemoval 是否还有待删除的像素
+func (c *carver) hasRemoval() bool {
+	for _, v := range c.bias {
+		if v < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// toImage 转回图片, 保持原图的通道数和精度
+func (c *carver) toImage() image.Image {
+	nc := 3
+	if c.gray {
+		nc = 1
+	}
+	planes := make([][]float64, nc)
+	for k := range planes {
+		planes[k] = make([]float64, c.w*c.h)
+	}
+	alpha := make([]float64, c.w*c.h)
+	for i, px := range c.pix {
+		for k := range planes {
+			planes[k][i] = px[k]
+		}
+		alpha[i] = px[3]
+	}
+	return planesToImage(planes, alpha, c.w, c.h, c.depth)
+}
+
+// SeamCarve 内容感知缩放到 w x h: 缩小时删除能量最低的缝, 放大时复制能量最低的缝
+// 先调整宽度再调整高度; protect 中白色的区域会被缝避开, 可以为 nil
+func (p *Picture) SeamCarve(p1 *Picture, w, h int, protect *Picture) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if w < 1 || h < 1 {
+		return errors.New("target size must be positive")
+	}
+	c, err := newCarver(p.Img, protect, nil)
+	if err != nil {
+		return
+	}
+	c.resizeWidth(w)
+	c.transpose()
+	c.resizeWidth(h)
+	c.transpose()
+	p.setResult(p1, c.toImage())
+	return
+}
+
+// RemoveObject 删除 remove 中白色区域的物体: 沿物体较窄的方向反复删除穿过该区域的缝, 直到物体完全消失
+// protect 为保护区域, 可以为 nil; restore 为 true 时再插入同样数量的缝恢复原尺寸
+func (p *Picture) RemoveObject(p1 *Picture, remove, protect *Picture, restore bool) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	if remove == nil {
+		return errors.New("removal mask is required")
+	}
+	c, err := newCarver(p.Img, protect, remove)
+	if err != nil {
+		return
+	}
+	// 待删除区域的外接矩形, 宽不大于高时删除竖直缝
+	minX, minY, maxX, maxY := c.w, c.h, -1, -1
+	for i := 0; i < c.h; i++ {
+		for j := 0; j < c.w; j++ {
+			if c.bias[i*c.w+j] >= 0 {
+				continue
+			}
+			if j < minX {
+				minX = j
+			}
+			if j > maxX {
+				maxX = j
+			}
+			if i < minY {
+				minY = i
+			}
+			if i > maxY {
+				maxY = i
+			}
+		}
+	}
+	if maxX < 0 {
+		return p.Copy(p1)
+	}
+	vertical := maxX-minX <= maxY-minY
+	if !vertical {
+		c.transpose()
+	}
+	origW := c.w
+	for c.hasRemoval() && c.w > 1 {
+		c.removeSeam(c.findSeam())
+	}
+	if restore {
+		c.enlarge(origW - c.w)
+	}
+	if !vertical {
+		c.transpose()
+	}
+	p.setResult(p1, c.toImage())
+	return
+}
@@ -0,0 +1,96 @@
+package myimage
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+锐化: USM(反锐化掩模) 和高提升滤波
+*/
+
+// toYCbCr RGB 平面转成 Y、Cb、Cr 平面(BT.601 全范围)
+func toYCbCr(r, g, b []float64) (y, cb, cr []float64) {
+	y = make([]float64, len(r))
+	cb = make([]float64, len(r))
+	cr = make([]float64, len(r))
+	for i := range r {
+		y[i] = 0.299*r[i] + 0.587*g[i] + 0.114*b[i]
+		cb[i] = -0.168736*r[i] - 0.331264*g[i] + 0.5*b[i]
+		cr[i] = 0.5*r[i] - 0.418688*g[i] - 0.081312*b[i]
+	}
+	return
+}
+
+// fromYCbCr toYCbCr 的逆变换
+func fromYCbCr(y, cb, cr []float64) (r, g, b []float64) {
+	r = make([]float64, len(y))
+	g = make([]float64, len(y))
+	b = make([]float64, len(y))
+	for i := range y {
+		r[i] = y[i] + 1.402*cr[i]
+		g[i] = y[i] - 0.344136*cb[i] - 0.714136*cr[i]
+		b[i] = y[i] + 1.772*cb[i]
+	}
+	return
+}
+
+// applyPlanes 对每个颜色平面执行 fn; lumaOnly 为 true 时只处理彩色图的亮度, 避免放大色边
+func (p *Picture) applyPlanes(p1 *Picture, lumaOnly bool, fn func(data []float64, w, h int) []float64) (err error) {
+	if p.Img == nil {
+		return errors.New("image not loaded")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	if lumaOnly && len(planes) == 3 {
+		y, cb, cr := toYCbCr(planes[0], planes[1], planes[2])
+		y = fn(y, w, h)
+		r, g, b := fromYCbCr(y, cb, cr)
+		planes = [][]float64{r, g, b}
+	} else {
+		for c := range planes {
+			planes[c] = fn(planes[c], w, h)
+		}
+	}
+	p.setResult(p1, planesToImage(planes, alpha, w, h, imageDepth(p.Img)))
+	return
+}
+
+// UnsharpMask USM 锐化: 原图 + amount·(原图 - 高斯模糊)
+// radius 为高斯模糊的标准差(像素); 差值不超过 threshold(0~255) 的像素保持不变, 以免放大噪声
+func (p *Picture) UnsharpMask(p1 *Picture, radius, amount, threshold float64, lumaOnly bool) (err error) {
+	if radius <= 0 {
+		return errors.New("radius must be positive")
+	}
+	k := gaussianKernel(radius, 0)
+	return p.applyPlanes(p1, lumaOnly, func(data []float64, w, h int) []float64 {
+		blur := convolveSeparable(data, w, h, k)
+		out := make([]float64, len(data))
+		for i, v := range data {
+			d := v - blur[i]
+			if math.Abs(d) > threshold {
+				v += amount * d
+			}
+			out[i] = v
+		}
+		return out
+	})
+}
+
+// HighBoost 高提升滤波: boost·原图 - 高斯模糊, boost >= 1; boost 为 1 时即高通滤波
+func (p *Picture) HighBoost(p1 *Picture, radius, boost float64, lumaOnly bool) (err error) {
+	if radius <= 0 {
+		return errors.New("radius must be positive")
+	}
+	if boost < 1 {
+		return errors.New("boost must be at least 1")
+	}
+	k := gaussianKernel(radius, 0)
+	return p.applyPlanes(p1, lumaOnly, func(data []float64, w, h int) []float64 {
+		blur := convolveSeparable(data, w, h, k)
+		out := make([]float64, len(data))
+		for i, v := range data {
+			out[i] = boost*v - blur[i]
+		}
+		return out
+	})
+}
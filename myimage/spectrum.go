@@ -0,0 +1,338 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+/*
+频域处理: 图片的二维FFT / 逆FFT、频谱可视化、理想 / 巴特沃斯 / 高斯滤波, 以及相位相关求平移
+*/
+
+// Spectrum 图片的二维频谱, 每个通道一个复数数组(灰度图 1 个, 彩色图 R、G、B 3 个)
+// 为了使用基2 FFT, 图片先以边界复制的方式扩展到 2 的幂大小, 直流分量位于下标 0
+type Spectrum struct {
+	Width     int // 频谱宽(2 的幂)
+	Height    int // 频谱高(2 的幂)
+	ImgWidth  int // 原图宽
+	ImgHeight int // 原图高
+	Channels  [][]complex128
+	alpha     []float64
+	depth     BitDepth
+	space     ColorSpace
+}
+
+// FFT 图片的二维傅里叶变换
+func (p *Picture) FFT() (*Spectrum, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	planes, alpha, w, h := colorPlanes(p.Img)
+	if w == 0 || h == 0 {
+		return nil, errors.New("empty image")
+	}
+	pw, ph := nextPow2(w), nextPow2(h)
+	s := &Spectrum{Width: pw, Height: ph, ImgWidth: w, ImgHeight: h, alpha: alpha, depth: imageDepth(p.Img), space: p.Space}
+	for _, pl := range planes {
+		data := make([]complex128, pw*ph)
+		for i := 0; i < ph; i++ {
+			y := i
+			if y >= h {
+				y = h - 1
+			}
+			for j := 0; j < pw; j++ {
+				x := j
+				if x >= w {
+					x = w - 1
+				}
+				data[i*pw+j] = complex(pl[y*w+x], 0)
+			}
+		}
+		fft2d(data, pw, ph, false)
+		s.Channels = append(s.Channels, data)
+	}
+	return s, nil
+}
+
+// Inverse 逆变换回图片, 裁剪回原图大小
+func (s *Spectrum) Inverse(p1 *Picture) (err error) {
+	if len(s.Channels) == 0 {
+		return errors.New("empty spectrum")
+	}
+	w, h := s.ImgWidth, s.ImgHeight
+	planes := make([][]float64, len(s.Channels))
+	for c, ch := range s.Channels {
+		data := append([]complex128(nil), ch...)
+		fft2d(data, s.Width, s.Height, true)
+		planes[c] = make([]float64, w*h)
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				planes[c][i*w+j] = real(data[i*s.Width+j])
+			}
+		}
+	}
+	alpha := s.alpha
+	if len(alpha) != w*h {
+		alpha = make([]float64, w*h)
+		for i := range alpha {
+			alpha[i] = 255
+		}
+	}
+	p1.Img = planesToImage(planes, alpha, w, h, s.depth)
+	p1.Space = s.space
+	return
+}
+
+// centered 把直流分量移到中心(fftshift)后的下标
+func (s *Spectrum) centered(i, j int) int {
+	return ((i+s.Height/2)%s.Height)*s.Width + (j+s.Width/2)%s.Width
+}
+
+// MagnitudeSpectrum 对数幅度谱 log(1+|F|)(各通道平均), 直流分量在中心
+// 周期性噪声在幅度谱上表现为远离中心的亮点; 可用 ToPicture(p1, 0, 0) 查看
+func (s *Spectrum) MagnitudeSpectrum() *Plane {
+	out := &Plane{s.Width, s.Height, make([]float64, s.Width*s.Height)}
+	for i := 0; i < s.Height; i++ {
+		for j := 0; j < s.Width; j++ {
+			var v float64
+			for _, ch := range s.Channels {
+				v += math.Log1p(cmplx.Abs(ch[i*s.Width+j]))
+			}
+			out.Data[s.centered(i, j)] = v / float64(len(s.Channels))
+		}
+	}
+	return out
+}
+
+// PhaseSpectrum 相位谱(-π~π, 各通道之和的相位), 直流分量在中心
+func (s *Spectrum) PhaseSpectrum() *Plane {
+	out := &Plane{s.Width, s.Height, make([]float64, s.Width*s.Height)}
+	for i := 0; i < s.Height; i++ {
+		for j := 0; j < s.Width; j++ {
+			var v complex128
+			for _, ch := range s.Channels {
+				v += ch[i*s.Width+j]
+			}
+			out.Data[s.centered(i, j)] = cmplx.Phase(v)
+		}
+	}
+	return out
+}
+
+// FilterKind 频域滤波器的形状
+type FilterKind uint8
+
+const (
+	FilterIdeal       FilterKind = iota // 理想滤波器, 截止处陡峭, 有振铃
+	FilterButterworth                   // 巴特沃斯, 陡峭程度由阶数决定
+	FilterGaussian                      // 高斯, 没有振铃
+)
+
+// FilterBand 频域滤波器保留的频段
+type FilterBand uint8
+
+const (
+	LowPass  FilterBand = iota // 低通, 平滑
+	HighPass                   // 高通, 保留边缘和细节
+	BandStop                   // 带阻, 去除以 Cutoff 为中心、宽 Width 的环形频段(周期性噪声)
+	BandPass                   // 带通
+)
+
+// ParseFilterKind 按名称解析: ideal、butterworth、gaussian
+func ParseFilterKind(name string) (FilterKind, error) {
+	switch strings.ToLower(name) {
+	case "ideal":
+		return FilterIdeal, nil
+	case "butterworth":
+		return FilterButterworth, nil
+	case "gaussian":
+		return FilterGaussian, nil
+	}
+	return FilterIdeal, fmt.Errorf("unknown filter kind %q", name)
+}
+
+// FrequencyFilter 频域滤波器
+// 频率以 周期/像素 为单位(0~0.5, 0.5 为奈奎斯特频率), 与图片和频谱大小无关
+type FrequencyFilter struct {
+	Kind   FilterKind
+	Band   FilterBand
+	Cutoff float64 // 截止频率; 带阻 / 带通时为频段中心
+	Width  float64 // 带阻 / 带通的频段宽度
+	Order  int     // 巴特沃斯阶数, 0 时为 2
+}
+
+// response 频率 d 处的增益(0~1)
+func (f FrequencyFilter) response(d float64) float64 {
+	n := float64(f.Order)
+	if n <= 0 {
+		n = 2
+	}
+	d0 := f.Cutoff
+	var low float64 // 低通或带阻的增益, 高通和带通取 1 - low
+	switch f.Band {
+	case LowPass, HighPass:
+		switch f.Kind {
+		case FilterIdeal:
+			if d <= d0 {
+				low = 1
+			}
+		case FilterButterworth:
+			low = 1 / (1 + math.Pow(d/d0, 2*n))
+		case FilterGaussian:
+			low = math.Exp(-d * d / (2 * d0 * d0))
+		}
+	default:
+		switch f.Kind {
+		case FilterIdeal:
+			low = 1
+			if math.Abs(d-d0) <= f.Width/2 {
+				low = 0
+			}
+		case FilterButterworth:
+			if den := d*d - d0*d0; den == 0 {
+				low = 0
+			} else {
+				low = 1 / (1 + math.Pow(d*f.Width/den, 2*n))
+			}
+		case FilterGaussian:
+			if d == 0 {
+				low = 1
+			} else {
+				e := (d*d - d0*d0) / (d * f.Width)
+				low = 1 - math.Exp(-e*e)
+			}
+		}
+	}
+	if f.Band == HighPass || f.Band == BandPass {
+		return 1 - low
+	}
+	return low
+}
+
+// Filter 用滤波器原地修改频谱
+func (s *Spectrum) Filter(f FrequencyFilter) error {
+	if f.Cutoff <= 0 {
+		return errors.New("cutoff frequency must be positive")
+	}
+	if (f.Band == BandStop || f.Band == BandPass) && f.Width <= 0 {
+		return errors.New("band width must be positive")
+	}
+	for i := 0; i < s.Height; i++ {
+		fy := float64(i) / float64(s.Height)
+		if i > s.Height/2 {
+			fy -= 1
+		}
+		for j := 0; j < s.Width; j++ {
+			fx := float64(j) / float64(s.Width)
+			if j > s.Width/2 {
+				fx -= 1
+			}
+			g := complex(f.response(math.Hypot(fx, fy)), 0)
+			for _, ch := range s.Channels {
+				ch[i*s.Width+j] *= g
+			}
+		}
+	}
+	return nil
+}
+
+// FrequencyFilter 频域滤波: FFT -> 乘以滤波器 -> 逆FFT
+func (p *Picture) FrequencyFilter(p1 *Picture, f FrequencyFilter) (err error) {
+	s, err := p.FFT()
+	if err != nil {
+		return
+	}
+	if err = s.Filter(f); err != nil {
+		return
+	}
+	return s.Inverse(p1)
+}
+
+// PhaseCorrelate 相位相关估计 other 相对 p 的平移, 即 other(x, y) ≈ p(x-dx, y-dy)
+// 结果精确到亚像素, response 为相关峰的强度(0~1), 越大越可信; 两图大小必须相同
+func (p *Picture) PhaseCorrelate(other *Picture) (dx, dy, response float64, err error) {
+	if p.Img == nil || other == nil || other.Img == nil {
+		return 0, 0, 0, errors.New("image not loaded")
+	}
+	a, w, h := lumaPlane(p.Img)
+	b, bw, bh := lumaPlane(other.Img)
+	if bw != w || bh != h {
+		return 0, 0, 0, errors.New("image sizes differ")
+	}
+	if w == 0 || h == 0 {
+		return 0, 0, 0, errors.New("empty image")
+	}
+	// 汉宁窗抑制边界不连续带来的频谱泄漏
+	pw, ph := nextPow2(w), nextPow2(h)
+	fa := make([]complex128, pw*ph)
+	fb := make([]complex128, pw*ph)
+	wx, wy := hannWindow(w), hannWindow(h)
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			fa[i*pw+j] = complex(a[i*w+j]*wx[j]*wy[i], 0)
+			fb[i*pw+j] = complex(b[i*w+j]*wx[j]*wy[i], 0)
+		}
+	}
+	fft2d(fa, pw, ph, false)
+	fft2d(fb, pw, ph, false)
+	// 归一化互功率谱
+	for i := range fa {
+		v := fb[i] * cmplx.Conj(fa[i])
+		if m := cmplx.Abs(v); m > 1e-12 {
+			fa[i] = v / complex(m, 0)
+		} else {
+			fa[i] = 0
+		}
+	}
+	fft2d(fa, pw, ph, true)
+
+	best, bx, by := math.Inf(-1), 0, 0
+	for i := 0; i < ph; i++ {
+		for j := 0; j < pw; j++ {
+			if v := real(fa[i*pw+j]); v > best {
+				best, bx, by = v, j, i
+			}
+		}
+	}
+	// 峰值 3x3 邻域加权质心求亚像素位置
+	var sum, sx, sy float64
+	for t := -1; t <= 1; t++ {
+		for s := -1; s <= 1; s++ {
+			v := real(fa[((by+t+ph)%ph)*pw+(bx+s+pw)%pw])
+			if v > 0 {
+				sum += v
+				sx += v * float64(s)
+				sy += v * float64(t)
+			}
+		}
+	}
+	dx, dy = float64(bx), float64(by)
+	if sum > 0 {
+		dx += sx / sum
+		dy += sy / sum
+	}
+	// 超过一半的位移对应负方向
+	if dx > float64(pw)/2 {
+		dx -= float64(pw)
+	}
+	if dy > float64(ph)/2 {
+		dy -= float64(ph)
+	}
+	return dx, dy, math.Min(sum, 1), nil
+}
+
+// hannWindow 长度为 n 的汉宁窗
+func hannWindow(n int) []float64 {
+	win := make([]float64, n)
+	for i := range win {
+		if n == 1 {
+			win[i] = 1
+		} else {
+			win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		}
+	}
+	return win
+}
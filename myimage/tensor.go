@@ -0,0 +1,246 @@
+package myimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+/*
+张量: 图片与 float32 数组之间的转换, 用于模型推理的前处理和结果可视化
+*/
+
+// Tensor float32 多维数组, 按行优先(C 顺序)存储
+type Tensor struct {
+	Shape []int
+	Data  []float32
+}
+
+// NewTensor 创建指定形状、元素全为 0 的张量
+func NewTensor(shape ...int) *Tensor {
+	n := 1
+	for _, s := range shape {
+		n *= s
+	}
+	return &Tensor{append([]int(nil), shape...), make([]float32, n)}
+}
+
+// Len 元素个数
+func (t *Tensor) Len() int {
+	n := 1
+	for _, s := range t.Shape {
+		n *= s
+	}
+	return n
+}
+
+// Index 取出第一维的第 i 个子张量(共享数据), 如从 NCHW 批量中取出一张 CHW
+func (t *Tensor) Index(i int) (*Tensor, error) {
+	if len(t.Shape) < 2 || i < 0 || i >= t.Shape[0] {
+		return nil, errors.New("tensor index out of range")
+	}
+	sub := &Tensor{Shape: append([]int(nil), t.Shape[1:]...)}
+	n := sub.Len()
+	sub.Data = t.Data[i*n : (i+1)*n]
+	return sub, nil
+}
+
+// Layout 张量的维度顺序
+type Layout uint8
+
+const (
+	LayoutHWC Layout = iota // 高 x 宽 x 通道
+	LayoutCHW               // 通道 x 高 x 宽
+)
+
+// ChannelOrder 颜色通道顺序
+type ChannelOrder uint8
+
+const (
+	OrderRGB ChannelOrder = iota
+	OrderBGR
+)
+
+// TensorOptions 转换选项, 每个通道: 值 = (像素值·Scale - Mean) / Std
+type TensorOptions struct {
+	Layout Layout
+	Order  ChannelOrder
+	Gray   bool       // 只输出一个亮度通道, 此时只使用 Mean[0] / Std[0]
+	Scale  float32    // 零值时为 1/255, 即像素值先缩放到 0~1
+	Mean   [3]float32 // 按 Order 之前的 R、G、B 顺序给出
+	Std    [3]float32 // 零值的分量视为 1
+}
+
+// ImageNetOptions ImageNet 预训练模型常用的归一化参数(CHW, RGB)
+var ImageNetOptions = TensorOptions{
+	Layout: LayoutCHW,
+	Mean:   [3]float32{0.485, 0.456, 0.406},
+	Std:    [3]float32{0.229, 0.224, 0.225},
+}
+
+// normalize 返回每个通道的缩放系数和偏移: 值 = 像素值·k + b
+func (o TensorOptions) normalize() (k, b [3]float32) {
+	scale := o.Scale
+	if scale == 0 {
+		scale = 1.0 / 255
+	}
+	for c := 0; c < 3; c++ {
+		std := o.Std[c]
+		if std == 0 {
+			std = 1
+		}
+		k[c] = scale / std
+		b[c] = -o.Mean[c] / std
+	}
+	return
+}
+
+// channels 输出通道数
+func (o TensorOptions) channels() int {
+	if o.Gray {
+		return 1
+	}
+	return 3
+}
+
+// shape 单张图片的张量形状
+func (o TensorOptions) shape(w, h int) []int {
+	if o.Layout == LayoutCHW {
+		return []int{o.channels(), h, w}
+	}
+	return []int{h, w, o.channels()}
+}
+
+// fillTensor 把图片写入 data(长度为 w·h·通道数), 16 位和浮点图片保持原精度
+func fillTensor(img image.Image, data []float32, o TensorOptions) {
+	planes, _, w, h := colorPlanes(img)
+	if len(planes) == 1 {
+		planes = [][]float64{planes[0], planes[0], planes[0]}
+	}
+	k, b := o.normalize()
+	nc := o.channels()
+	// 各输出通道对应的 RGB 平面下标
+	src := [3]int{0, 1, 2}
+	if o.Order == OrderBGR {
+		src = [3]int{2, 1, 0}
+	}
+	parallelRows(h, func(y0, y1 int) {
+		for i := y0; i < y1; i++ {
+			for j := 0; j < w; j++ {
+				idx := i*w + j
+				for c := 0; c < nc; c++ {
+					var v float32
+					if o.Gray {
+						v = (0.299*float32(planes[0][idx]) + 0.587*float32(planes[1][idx]) + 0.114*float32(planes[2][idx])) * k[0]
+						v += b[0]
+					} else {
+						s := src[c]
+						v = float32(planes[s][idx])*k[s] + b[s]
+					}
+					if o.Layout == LayoutCHW {
+						data[(c*h+i)*w+j] = v
+					} else {
+						data[idx*nc+c] = v
+					}
+				}
+			}
+		}
+	})
+}
+
+// ToTensor 图片转成 HWC 或 CHW 的 float32 张量
+func (p *Picture) ToTensor(opts TensorOptions) (*Tensor, error) {
+	if p.Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	w, h := p.GetSize()
+	t := NewTensor(opts.shape(w, h)...)
+	fillTensor(p.Img, t.Data, opts)
+	return t, nil
+}
+
+// PicturesToTensor 多张同样大小的图片转成一个批量张量(NHWC 或 NCHW)
+func PicturesToTensor(pics []*Picture, opts TensorOptions) (*Tensor, error) {
+	if len(pics) == 0 {
+		return nil, errors.New("no pictures")
+	}
+	if pics[0].Img == nil {
+		return nil, errors.New("image not loaded")
+	}
+	w, h := pics[0].GetSize()
+	shape := append([]int{len(pics)}, opts.shape(w, h)...)
+	t := NewTensor(shape...)
+	n := t.Len() / len(pics)
+	for i, p := range pics {
+		if p.Img == nil {
+			return nil, errors.New("image not loaded")
+		}
+		if pw, ph := p.GetSize(); pw != w || ph != h {
+			return nil, fmt.Errorf("picture %d is %dx%d, want %dx%d", i, pw, ph, w, h)
+		}
+		fillTensor(p.Img, t.Data[i*n:(i+1)*n], opts)
+	}
+	return t, nil
+}
+
+// TensorToPicture 张量转回图片(反归一化后裁剪到 0~255), 用于可视化模型输出
+// 支持 HW(灰度)以及 opts.Layout 指定的 HWC / CHW, 通道数为 1 时生成灰度图
+func TensorToPicture(t *Tensor, opts TensorOptions) (*Picture, error) {
+	var w, h, nc int
+	switch {
+	case len(t.Shape) == 2:
+		h, w, nc = t.Shape[0], t.Shape[1], 1
+	case len(t.Shape) == 3 && opts.Layout == LayoutCHW:
+		nc, h, w = t.Shape[0], t.Shape[1], t.Shape[2]
+	case len(t.Shape) == 3:
+		h, w, nc = t.Shape[0], t.Shape[1], t.Shape[2]
+	default:
+		return nil, fmt.Errorf("unsupported tensor shape %v", t.Shape)
+	}
+	if nc != 1 && nc != 3 {
+		return nil, fmt.Errorf("unsupported channel count %d", nc)
+	}
+	if len(t.Data) != w*h*nc {
+		return nil, errors.New("tensor data does not match its shape")
+	}
+	k, b := opts.normalize()
+	at := func(c, i, j int) float32 {
+		if len(t.Shape) == 2 {
+			return t.Data[i*w+j]
+		}
+		if opts.Layout == LayoutCHW {
+			return t.Data[(c*h+i)*w+j]
+		}
+		return t.Data[(i*w+j)*nc+c]
+	}
+	// 像素值 = (值 - b) / k
+	px := func(v float32, c int) uint8 {
+		return Clip((v-b[c])/k[c]+0.5, 0, 255)
+	}
+
+	if nc == 1 {
+		newImg := image.NewGray(image.Rect(0, 0, w, h))
+		for i := 0; i < h; i++ {
+			for j := 0; j < w; j++ {
+				newImg.Pix[i*newImg.Stride+j] = px(at(0, i, j), 0)
+			}
+		}
+		return &Picture{Img: newImg}, nil
+	}
+	src := [3]int{0, 1, 2}
+	if opts.Order == OrderBGR {
+		src = [3]int{2, 1, 0}
+	}
+	newImg := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < h; i++ {
+		for j := 0; j < w; j++ {
+			var c [3]uint8
+			for ch := 0; ch < 3; ch++ {
+				c[src[ch]] = px(at(ch, i, j), src[ch])
+			}
+			newImg.SetRGBA(j, i, color.RGBA{c[0], c[1], c[2], 255})
+		}
+	}
+	return &Picture{Img: newImg}, nil
+}